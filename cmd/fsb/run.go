@@ -4,12 +4,18 @@ import (
 	"EverythingSuckz/fsb/config"
 	"EverythingSuckz/fsb/internal/bot"
 	"EverythingSuckz/fsb/internal/cache"
+	"EverythingSuckz/fsb/internal/commands"
 	"EverythingSuckz/fsb/internal/database"
 	"EverythingSuckz/fsb/internal/routes"
+	"EverythingSuckz/fsb/internal/templates"
 	"EverythingSuckz/fsb/internal/types"
 	"EverythingSuckz/fsb/internal/utils"
-	"fmt"
+	"context"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -33,6 +39,9 @@ func runApp(cmd *cobra.Command, args []string) {
 	mainLogger := log.Named("Main")
 	mainLogger.Info("Starting server")
 	config.Load(log, cmd)
+	if err := templates.Load(log); err != nil {
+		log.Panic("Failed to load message templates", zap.Error(err))
+	}
 	router := getRouter(log)
 
 	mainBot, err := bot.StartClient(log)
@@ -48,6 +57,9 @@ func runApp(cmd *cobra.Command, args []string) {
 	
 	cache.InitCache(log)
 	cache.InitStatsCache(log)
+	if err := utils.LoadGeoIP(config.ValueOf.GeoIPDatabase); err != nil {
+		log.Error("Failed to load GEOIP_DB, countries will not be recorded", zap.Error(err))
+	}
 	workers, err := bot.StartWorkers(log)
 	if err != nil {
 		log.Panic("Failed to start workers", zap.Error(err))
@@ -58,10 +70,56 @@ func runApp(cmd *cobra.Command, args []string) {
 	mainLogger.Info("Server started", zap.Int("port", config.ValueOf.Port))
 	mainLogger.Info("File Stream Bot", zap.String("version", versionString))
 	mainLogger.Sugar().Infof("Server is running at %s", config.ValueOf.Host)
-	err = router.Run(fmt.Sprintf(":%d", config.ValueOf.Port))
-	if err != nil {
-		mainLogger.Sugar().Fatalln(err)
+	var adminRouter http.Handler
+	if len(config.ValueOf.AdminListen) > 0 {
+		adminRouter = getAdminRouter(log)
 	}
+	servers := startServers(mainLogger, router, adminRouter)
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	mainLogger.Info("Shutting down", zap.String("signal", sig.String()))
+	go func() {
+		<-signals
+		mainLogger.Warn("Second signal received, exiting without waiting")
+		os.Exit(1)
+	}()
+	shutdown(mainLogger, servers)
+}
+
+// shutdown stops accepting connections, waits up to SHUTDOWN_TIMEOUT
+// seconds for the active streams to finish, then stops the broadcasts and
+// the bots and writes the pending stats.
+func shutdown(log *zap.Logger, servers []*http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.ValueOf.ShutdownTimeout)*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		go func(server *http.Server) {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				log.Warn("Streams still active after SHUTDOWN_TIMEOUT, closing them", zap.String("addr", server.Addr), zap.Error(err))
+				_ = server.Close()
+			}
+		}(server)
+	}
+	wg.Wait()
+	routes.Stop()
+	log.Info("HTTP servers stopped")
+
+	commands.StopBroadcasts()
+	bot.StopWorkers()
+	bot.StopUserBot()
+	if err := cache.GetStatsCache().FlushTraffic(); err != nil {
+		log.Error("Failed to save traffic stats", zap.Error(err))
+	}
+	if err := database.Close(); err != nil {
+		log.Error("Failed to close database", zap.Error(err))
+	}
+	log.Info("Stopped")
+	_ = utils.Logger.Sync()
 }
 
 func getRouter(log *zap.Logger) *gin.Engine {
@@ -70,8 +128,30 @@ func getRouter(log *zap.Logger) *gin.Engine {
 	} else {
 		gin.SetMode(gin.ReleaseMode)
 	}
-	router := gin.Default()
+	// requests are logged by the access log of routes.Load
+	router := gin.New()
+	cloudflare, err := config.ValueOf.CloudflareRanges()
+	if err != nil {
+		log.Sugar().Fatalf("Invalid CLOUDFLARE_IPS_FILE: %s", err)
+	}
+	cloudflareHeader, err := routes.CloudflareHeader(cloudflare)
+	if err != nil {
+		log.Sugar().Fatalf("Invalid CLOUDFLARE_IPS_FILE: %s", err)
+	}
+	router.Use(gin.Recovery(), cloudflareHeader)
+	// client IPs are only taken from these headers when the request comes
+	// from one of TRUSTED_PROXIES, and CF-Connecting-IP only from Cloudflare
+	router.RemoteIPHeaders = []string{"CF-Connecting-IP", "X-Forwarded-For", "X-Real-IP"}
+	proxies, err := config.ValueOf.TrustedProxyRanges()
+	if err != nil {
+		log.Sugar().Fatalf("Invalid TRUSTED_PROXIES: %s", err)
+	}
+	if err := router.SetTrustedProxies(proxies); err != nil {
+		log.Sugar().Fatalf("Invalid TRUSTED_PROXIES: %s", err)
+	}
 	router.Use(gin.ErrorLogger())
+	routes.Load(log, router)
+	// after routes.Load, so "/" goes through its middleware too
 	router.GET("/", func(ctx *gin.Context) {
 		ctx.JSON(http.StatusOK, types.RootResponse{
 			Message: "Server is running.",
@@ -80,6 +160,14 @@ func getRouter(log *zap.Logger) *gin.Engine {
 			Version: versionString,
 		})
 	})
-	routes.Load(log, router)
+	return router
+}
+
+// getAdminRouter returns the router of ADMIN_LISTEN, without the request
+// logs of the public one.
+func getAdminRouter(log *zap.Logger) *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Recovery())
+	routes.LoadAdmin(log, router)
 	return router
 }
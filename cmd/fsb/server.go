@@ -0,0 +1,150 @@
+package main
+
+import (
+	"EverythingSuckz/fsb/config"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// startServers serves router on the LISTEN addresses, or PORT, and admin on
+// the ADMIN_LISTEN addresses. With TLS enabled, router is also served on
+// TLS_PORT over HTTPS and HTTP/2, and the plain addresses redirect to HTTPS,
+// unless HTTPS_REDIRECT is false, and answer ACME http-01 challenges.
+func startServers(log *zap.Logger, router, admin http.Handler) []*http.Server {
+	var servers []*http.Server
+	plain := router
+	if config.ValueOf.TLSEnabled() {
+		if config.ValueOf.HTTPSRedirect {
+			plain = http.HandlerFunc(redirectToHTTPS)
+		}
+		tlsConfig, handler, stopTLS, err := newTLSConfig(log, plain)
+		if err != nil {
+			log.Sugar().Fatalf("Failed to set up TLS: %s", err)
+		}
+		plain = handler
+		tlsServer := &http.Server{
+			Addr:      fmt.Sprintf(":%d", config.ValueOf.TLSPort),
+			Handler:   router,
+			TLSConfig: tlsConfig,
+		}
+		tlsServer.RegisterOnShutdown(stopTLS)
+		servers = append(servers, listenAndServe(log, tlsServer, true, 0))
+	}
+	mode, err := strconv.ParseUint(config.ValueOf.SocketMode, 8, 32)
+	if err != nil || mode > 0777 {
+		log.Sugar().Fatalf("Invalid LISTEN_SOCKET_MODE %q, use an octal mode such as 0660", config.ValueOf.SocketMode)
+	}
+	for _, addr := range listenAddresses() {
+		servers = append(servers, listenAndServe(log, &http.Server{Addr: addr, Handler: plain}, false, os.FileMode(mode)))
+	}
+	for _, addr := range config.ValueOf.AdminListen {
+		servers = append(servers, listenAndServe(log, &http.Server{Addr: addr, Handler: admin}, false, adminSocketMode))
+	}
+	return servers
+}
+
+// adminSocketMode only lets the user running the bot connect to the unix
+// sockets of ADMIN_LISTEN, since they serve pprof and the config.
+const adminSocketMode os.FileMode = 0600
+
+// listenAddresses returns LISTEN, or PORT on every interface if it's empty.
+func listenAddresses() []string {
+	if len(config.ValueOf.Listen) > 0 {
+		return config.ValueOf.Listen
+	}
+	return []string{fmt.Sprintf("tcp://:%d", config.ValueOf.Port)}
+}
+
+// listenAndServe binds server.Addr and serves on it in the background.
+// socketMode is the permissions of the socket file of unix:// addresses.
+func listenAndServe(log *zap.Logger, server *http.Server, useTLS bool, socketMode os.FileMode) *http.Server {
+	listener, err := listen(server.Addr, socketMode)
+	if err != nil {
+		log.Sugar().Fatalf("Failed to listen on %s: %s", server.Addr, err)
+	}
+	if strings.HasPrefix(server.Addr, "unix://") {
+		server.Handler = fromLocalhost(server.Handler)
+	}
+	log.Info("Listening", zap.String("addr", server.Addr), zap.Bool("tls", useTLS))
+	go serve(log, server, listener, useTLS)
+	return server
+}
+
+// listen binds a tcp://host:port or unix:///path address. Addresses without
+// a scheme are TCP. The socket file of unix addresses gets socketMode.
+func listen(addr string, socketMode os.FileMode) (net.Listener, error) {
+	network, address, found := strings.Cut(addr, "://")
+	if !found {
+		network, address = "tcp", addr
+	}
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		return net.Listen(network, address)
+	case "unix":
+		// a socket left behind by a crash would make Listen fail
+		if info, err := os.Stat(address); err == nil && info.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(address); err != nil {
+				return nil, err
+			}
+		}
+		listener, err := net.Listen("unix", address)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(address, socketMode); err != nil {
+			listener.Close()
+			return nil, err
+		}
+		return listener, nil
+	default:
+		return nil, fmt.Errorf("unknown network %q, use tcp:// or unix://", network)
+	}
+}
+
+// fromLocalhost gives the requests of a unix socket, which have no remote
+// address, the address of localhost so the client IP can be read from the
+// headers of a proxy when TRUSTED_PROXIES includes 127.0.0.1.
+func fromLocalhost(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.RemoteAddr = "127.0.0.1:0"
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// serve runs server on listener until it's shut down.
+func serve(log *zap.Logger, server *http.Server, listener net.Listener, useTLS bool) {
+	var err error
+	if useTLS {
+		// the certificates come from TLSConfig
+		err = server.ServeTLS(listener, "", "")
+	} else {
+		err = server.Serve(listener)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Sugar().Fatalln(err)
+	}
+}
+
+// redirectToHTTPS sends plain HTTP requests to the same URL on TLS_PORT.
+func redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(r.Host); err == nil {
+		host = h
+	} else {
+		// IPv6 hosts without a port keep their brackets
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	}
+	if config.ValueOf.TLSPort != 443 {
+		host = net.JoinHostPort(host, strconv.Itoa(config.ValueOf.TLSPort))
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"EverythingSuckz/fsb/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		name    string
+		tlsPort int
+		host    string
+		target  string
+		want    string
+	}{
+		{name: "default port", tlsPort: 443, host: "example.com", target: "/stream/1?hash=abc", want: "https://example.com/stream/1?hash=abc"},
+		{name: "plain port dropped", tlsPort: 443, host: "example.com:8080", target: "/", want: "https://example.com/"},
+		{name: "custom port", tlsPort: 8443, host: "example.com:8080", target: "/watch/1", want: "https://example.com:8443/watch/1"},
+		{name: "custom port without plain port", tlsPort: 8443, host: "example.com", target: "/", want: "https://example.com:8443/"},
+		{name: "ipv4", tlsPort: 443, host: "192.0.2.1:80", target: "/", want: "https://192.0.2.1/"},
+		{name: "ipv6", tlsPort: 443, host: "[2001:db8::1]:80", target: "/", want: "https://[2001:db8::1]/"},
+		{name: "ipv6 without port", tlsPort: 443, host: "[2001:db8::1]", target: "/", want: "https://[2001:db8::1]/"},
+		{name: "ipv6 custom port", tlsPort: 8443, host: "[2001:db8::1]", target: "/", want: "https://[2001:db8::1]:8443/"},
+		{name: "escaped path", tlsPort: 443, host: "example.com", target: "/dl/1/a%20b.mp4", want: "https://example.com/dl/1/a%20b.mp4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.ValueOf.TLSPort = tt.tlsPort
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			r.Host = tt.host
+			w := httptest.NewRecorder()
+			redirectToHTTPS(w, r)
+			if w.Code != http.StatusMovedPermanently {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusMovedPermanently)
+			}
+			if got := w.Header().Get("Location"); got != tt.want {
+				t.Errorf("Location = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"EverythingSuckz/fsb/config"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// certCheckInterval is how often the certificate files are checked for
+// changes.
+const certCheckInterval = 30 * time.Second
+
+// certReloader serves the certificate in TLS_CERT_FILE and TLS_KEY_FILE,
+// loading it again when either file changes so renewed certificates are
+// picked up without a restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	log      *zap.Logger
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newCertReloader(log *zap.Logger, certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, log: log.Named("tls"), stop: make(chan struct{})}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	go r.watch()
+	return r, nil
+}
+
+// load reads the certificate files if they changed since the last load.
+func (r *certReloader) load() error {
+	modTime, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+	r.mu.RLock()
+	unchanged := r.cert != nil && !modTime.After(r.modTime)
+	r.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mu.Unlock()
+	r.log.Info("Loaded TLS certificate", zap.String("file", r.certFile))
+	return nil
+}
+
+func (r *certReloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// watch reloads the certificate when its files change. A certificate that
+// fails to load, such as one written halfway, is retried on the next check
+// while the previous one keeps being served.
+func (r *certReloader) watch() {
+	ticker := time.NewTicker(certCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			if err := r.load(); err != nil {
+				r.log.Error("Failed to reload TLS certificate, serving the previous one", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Stop ends the checks of watch.
+func (r *certReloader) Stop() {
+	r.stopOnce.Do(func() { close(r.stop) })
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// newTLSConfig returns the TLS configuration of the HTTPS server, the
+// handler for ACME http-01 challenges wrapping fallback when certificates
+// come from ACME, or fallback itself otherwise, and a function that stops
+// watching the certificate files on shutdown.
+func newTLSConfig(log *zap.Logger, fallback http.Handler) (*tls.Config, http.Handler, func(), error) {
+	if len(config.ValueOf.ACMEDomains) == 0 {
+		reloader, err := newCertReloader(log, config.ValueOf.TLSCertFile, config.ValueOf.TLSKeyFile)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return &tls.Config{
+			GetCertificate: reloader.GetCertificate,
+			NextProtos:     []string{"h2", "http/1.1"},
+			MinVersion:     tls.VersionTLS12,
+		}, fallback, reloader.Stop, nil
+	}
+
+	client := &acme.Client{DirectoryURL: config.ValueOf.ACMEDirectory}
+	if config.ValueOf.ACMECACert != "" {
+		// test directories such as Pebble serve their API with their own CA
+		pem, err := os.ReadFile(config.ValueOf.ACMECACert)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, nil, nil, errors.New("no certificates found in ACME_CA_CERT")
+		}
+		client.HTTPClient = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}},
+		}
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(config.ValueOf.ACMECacheDir),
+		HostPolicy: autocert.HostWhitelist(config.ValueOf.ACMEDomains...),
+		Email:      config.ValueOf.ACMEEmail,
+		Client:     client,
+	}
+	log.Named("tls").Info("Using ACME certificates",
+		zap.Strings("domains", config.ValueOf.ACMEDomains),
+		zap.String("directory", config.ValueOf.ACMEDirectory))
+	tlsConfig := manager.TLSConfig()
+	tlsConfig.MinVersion = tls.VersionTLS12
+	return tlsConfig, manager.HTTPHandler(fallback), func() {}, nil
+}
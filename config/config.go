@@ -1,11 +1,13 @@
 package config
 
 import (
+	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
@@ -20,23 +22,63 @@ var ValueOf = &config{}
 
 type config struct {
 	APIID           int64    `envconfig:"API_ID" required:"true"`
-	APIHash         string   `envconfig:"API_HASH" required:"true"`
-	BotToken        string   `envconfig:"BOT_TOKEN" required:"true"`
+	APIHash         string   `envconfig:"API_HASH" required:"true" redact:"true"`
+	BotToken        string   `envconfig:"BOT_TOKEN" required:"true" redact:"true"`
 	LogChannelID    int64    `envconfig:"LOG_CHANNEL" required:"true"`
 	Host            string   `envconfig:"HOST"`
 	Port            int      `envconfig:"PORT" default:"8080"`
+	Listen          []string `envconfig:"LISTEN"`
+	AdminListen     []string `envconfig:"ADMIN_LISTEN"`
+	SocketMode      string   `envconfig:"LISTEN_SOCKET_MODE" default:"0660"`
+	PublicMetrics   bool     `envconfig:"PUBLIC_METRICS" default:"false"`
+	ShutdownTimeout int      `envconfig:"SHUTDOWN_TIMEOUT" default:"30"`
+	TLSPort         int      `envconfig:"TLS_PORT" default:"443"`
+	TLSCertFile     string   `envconfig:"TLS_CERT_FILE"`
+	TLSKeyFile      string   `envconfig:"TLS_KEY_FILE"`
+	HTTPSRedirect   bool     `envconfig:"HTTPS_REDIRECT" default:"true"`
+	ACMEDomains     []string `envconfig:"ACME_DOMAINS"`
+	ACMEEmail       string   `envconfig:"ACME_EMAIL"`
+	ACMEDirectory   string   `envconfig:"ACME_DIRECTORY_URL" default:"https://acme-v02.api.letsencrypt.org/directory"`
+	ACMECACert      string   `envconfig:"ACME_CA_CERT"`
+	ACMECacheDir    string   `envconfig:"ACME_CACHE_DIR" default:".acme"`
 	WorkerURL       string   `envconfig:"WORKER_URL" required:"true"`
 	MaxCacheSize    int64    `envconfig:"MAX_CACHE_SIZE" default:"10737418240"`
 	CacheDirectory  string   `envconfig:"CACHE_DIRECTORY" default:".cache"`
 	GithubOwner     string   `envconfig:"GITHUB_OWNER"`
 	GithubRepo      string   `envconfig:"GITHUB_REPO"`
 	GithubDbPath    string   `envconfig:"GITHUB_DB_PATH" default:"storage/database.json"`
-	GithubToken     string   `envconfig:"GITHUB_TOKEN"`
+	GithubToken     string   `envconfig:"GITHUB_TOKEN" redact:"true"`
 	AllowedUsers    []int64  `envconfig:"ALLOWED_USERS"`
+	AdminIDs        []int64  `envconfig:"ADMIN_IDS"`
+	BroadcastRate   int      `envconfig:"BROADCAST_RATE" default:"20"`
+	FilesPerDay     int64    `envconfig:"QUOTA_FILES_PER_DAY" default:"0"`
+	BytesPerDay     int64    `envconfig:"QUOTA_BYTES_PER_DAY" default:"0"`
+	MaxFileSize     int64    `envconfig:"MAX_FILE_SIZE" default:"0"`
+	MaxStreams      int64    `envconfig:"MAX_STREAMS_PER_USER" default:"0"`
 	ForceSubChannel string   `envconfig:"FORCE_SUB_CHANNEL"`
+	ForceSubs       []string `envconfig:"FORCE_SUB_CHANNELS"`
+	ForceSubTTL     int      `envconfig:"FORCE_SUB_CACHE_TTL" default:"300"`
+	ViewerTokens    bool     `envconfig:"VIEWER_TOKENS" default:"false"`
+	ViewerTokenTTL  int      `envconfig:"VIEWER_TOKEN_TTL" default:"3600"`
+	TokenSecret     string   `envconfig:"VIEWER_TOKEN_SECRET" redact:"true"`
 	HashLength      int      `envconfig:"HASH_LENGTH" default:"6"`
 	UsePublicIP     bool     `envconfig:"USE_PUBLIC_IP" default:"false"`
-	MultiTokens     []string
+	TrustedProxies  []string `envconfig:"TRUSTED_PROXIES"`
+	CloudflareIPs   string   `envconfig:"CLOUDFLARE_IPS_FILE"`
+	CORSOrigins     []string `envconfig:"CORS_ORIGINS"`
+	AllowedReferers []string `envconfig:"ALLOWED_REFERERS"`
+	IPRateLimit     float64  `envconfig:"IP_RATE_LIMIT" default:"0"`
+	IPRateBurst     int      `envconfig:"IP_RATE_BURST" default:"20"`
+	LinkRateLimit   float64  `envconfig:"LINK_RATE_LIMIT" default:"0"`
+	LinkRateBurst   int      `envconfig:"LINK_RATE_BURST" default:"20"`
+	StreamBandwidth int64    `envconfig:"STREAM_BANDWIDTH_LIMIT" default:"0"`
+	StatsTimezone   string   `envconfig:"STATS_TIMEZONE" default:"UTC"`
+	StatsRetention  int      `envconfig:"STATS_RETENTION_DAYS" default:"90"`
+	GeoIPDatabase   string   `envconfig:"GEOIP_DB"`
+	ChannelCaption  string   `envconfig:"CHANNEL_CAPTION_TEMPLATE"`
+	TemplatesFile   string   `envconfig:"TEMPLATES_FILE"`
+	LinkButtons     string   `envconfig:"LINK_BUTTONS" default:"stream,download;share;revoke,delete;playlist"`
+	MultiTokens     []string `redact:"true"`
 }
 
 var botTokenRegex = regexp.MustCompile(`MULTI\_TOKEN\d+=(.*)`)
@@ -56,9 +98,8 @@ func (c *config) setupEnvVars(log *zap.Logger, cmd *cobra.Command) {
 		log.Fatal("Env processing failed", zap.Error(err))
 	}
 
-	ip, _ := getIP(c.UsePublicIP)
 	if c.Host == "" {
-		c.Host = "http://" + ip + ":" + strconv.Itoa(c.Port)
+		c.Host = c.defaultHost()
 	}
 
 	for _, env := range os.Environ() {
@@ -71,6 +112,51 @@ func (c *config) setupEnvVars(log *zap.Logger, cmd *cobra.Command) {
 	}
 }
 
+// TLSEnabled reports whether files are served over HTTPS, with the
+// certificate in TLS_CERT_FILE or one obtained for ACME_DOMAINS.
+func (c *config) TLSEnabled() bool {
+	return len(c.ACMEDomains) != 0 || (c.TLSCertFile != "" && c.TLSKeyFile != "")
+}
+
+// defaultHost is HOST when it isn't set: the first of ACME_DOMAINS, or else
+// the IP of this machine, with the port files are served on.
+func (c *config) defaultHost() string {
+	if !c.TLSEnabled() {
+		ip, _ := getIP(c.UsePublicIP)
+		return "http://" + ip + ":" + strconv.Itoa(c.Port)
+	}
+	host := ""
+	if len(c.ACMEDomains) != 0 {
+		host = c.ACMEDomains[0]
+	} else {
+		host, _ = getIP(c.UsePublicIP)
+	}
+	if c.TLSPort != 443 {
+		host += ":" + strconv.Itoa(c.TLSPort)
+	}
+	return "https://" + host
+}
+
+// Redacted returns the loaded configuration as "ENV_NAME=value" lines, with
+// the values of secret fields hidden.
+func (c *config) Redacted() []string {
+	var lines []string
+	val := reflect.ValueOf(c).Elem()
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Type().Field(i)
+		name := field.Tag.Get("envconfig")
+		if name == "" {
+			name = field.Name
+		}
+		value := fmt.Sprint(val.Field(i).Interface())
+		if field.Tag.Get("redact") == "true" && !val.Field(i).IsZero() {
+			value = "[redacted]"
+		}
+		lines = append(lines, name+"="+value)
+	}
+	return lines
+}
+
 func Load(log *zap.Logger, cmd *cobra.Command) {
 	ValueOf.setupEnvVars(log, cmd)
 	ValueOf.LogChannelID = int64(stripInt(log, int(ValueOf.LogChannelID)))
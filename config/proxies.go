@@ -0,0 +1,56 @@
+package config
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// cloudflareIPs is the list of Cloudflare ranges built into the binary.
+//
+//go:embed cloudflare_ips.txt
+var cloudflareIPs string
+
+// TrustedProxyRanges returns TRUSTED_PROXIES with "cloudflare" replaced by
+// the Cloudflare ranges.
+func (c *config) TrustedProxyRanges() ([]string, error) {
+	var ranges []string
+	for _, proxy := range c.TrustedProxies {
+		if !strings.EqualFold(proxy, "cloudflare") {
+			ranges = append(ranges, proxy)
+			continue
+		}
+		cloudflare, err := c.CloudflareRanges()
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, cloudflare...)
+	}
+	return ranges, nil
+}
+
+// CloudflareRanges returns the Cloudflare ranges, read from
+// CLOUDFLARE_IPS_FILE or else the list built into the binary.
+func (c *config) CloudflareRanges() ([]string, error) {
+	if c.CloudflareIPs == "" {
+		return parseRanges(cloudflareIPs), nil
+	}
+	data, err := os.ReadFile(c.CloudflareIPs)
+	if err != nil {
+		return nil, fmt.Errorf("reading CLOUDFLARE_IPS_FILE: %w", err)
+	}
+	return parseRanges(string(data)), nil
+}
+
+// parseRanges returns the lines of list, skipping blank lines and comments.
+func parseRanges(list string) []string {
+	var ranges []string
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			ranges = append(ranges, line)
+		}
+	}
+	return ranges
+}
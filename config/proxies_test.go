@@ -0,0 +1,72 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseRanges(t *testing.T) {
+	tests := []struct {
+		name string
+		list string
+		want []string
+	}{
+		{name: "empty", list: "", want: nil},
+		{name: "one", list: "10.0.0.0/8", want: []string{"10.0.0.0/8"}},
+		{name: "comments and blank lines", list: "# ranges\n\n10.0.0.0/8\n  # indented comment\n2001:db8::/32\n", want: []string{"10.0.0.0/8", "2001:db8::/32"}},
+		{name: "spaces and CRLF", list: "  10.0.0.0/8  \r\n192.168.0.1\r\n", want: []string{"10.0.0.0/8", "192.168.0.1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRanges(tt.list); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRanges() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustedProxyRanges(t *testing.T) {
+	builtin := parseRanges(cloudflareIPs)
+	if len(builtin) == 0 {
+		t.Fatal("no built-in Cloudflare ranges")
+	}
+	file := filepath.Join(t.TempDir(), "cloudflare.txt")
+	if err := os.WriteFile(file, []byte("# updated list\n198.51.100.0/24\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name          string
+		proxies       []string
+		cloudflareIPs string
+		want          []string
+		wantErr       bool
+	}{
+		{name: "none", proxies: nil, want: nil},
+		{name: "plain ranges", proxies: []string{"127.0.0.1", "10.0.0.0/8"}, want: []string{"127.0.0.1", "10.0.0.0/8"}},
+		{name: "built-in cloudflare", proxies: []string{"127.0.0.1", "Cloudflare"}, want: append([]string{"127.0.0.1"}, builtin...)},
+		{name: "cloudflare file", proxies: []string{"cloudflare", "10.0.0.0/8"}, cloudflareIPs: file, want: []string{"198.51.100.0/24", "10.0.0.0/8"}},
+		// the file is only read when cloudflare is trusted
+		{name: "missing file unused", proxies: []string{"10.0.0.0/8"}, cloudflareIPs: filepath.Join(t.TempDir(), "missing.txt"), want: []string{"10.0.0.0/8"}},
+		{name: "missing file", proxies: []string{"cloudflare"}, cloudflareIPs: filepath.Join(t.TempDir(), "missing.txt"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &config{TrustedProxies: tt.proxies, CloudflareIPs: tt.cloudflareIPs}
+			got, err := c.TrustedProxyRanges()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("TrustedProxyRanges() = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TrustedProxyRanges() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
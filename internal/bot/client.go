@@ -11,6 +11,7 @@ import (
 	"github.com/celestix/gotgproto"
 	"github.com/celestix/gotgproto/sessionMaker"
 	"github.com/glebarez/sqlite"
+	"github.com/gotd/td/telegram"
 )
 
 var Bot *gotgproto.Client
@@ -32,6 +33,7 @@ func StartClient(log *zap.Logger) (*gotgproto.Client, error) {
 					sqlite.Open("fsb.session"),
 				),
 				DisableCopyright: true,
+				Middlewares:      []telegram.Middleware{metricsMiddleware("main")},
 			},
 		)
 		resultChan <- struct {
@@ -47,6 +49,7 @@ func StartClient(log *zap.Logger) (*gotgproto.Client, error) {
 		if result.err != nil {
 			return nil, result.err
 		}
+		commands.WorkersInfo = WorkersInfo
 		commands.Load(log, result.client.Dispatcher)
 		log.Info("Client started", zap.String("username", result.client.Self.Username))
 		Bot = result.client
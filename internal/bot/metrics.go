@@ -0,0 +1,36 @@
+package bot
+
+import (
+	"EverythingSuckz/fsb/internal/metrics"
+	"context"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// metricsMiddleware records the upload.getFile calls and the flood waits of
+// a bot. It must be the last middleware so it sees every attempt the flood
+// waiter makes.
+func metricsMiddleware(worker string) telegram.Middleware {
+	return telegram.MiddlewareFunc(func(next tg.Invoker) telegram.InvokeFunc {
+		return func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+			start := time.Now()
+			err := next.Invoke(ctx, input, output)
+			if wait, ok := tgerr.AsFloodWait(err); ok {
+				metrics.FloodWaits.Inc(worker)
+				metrics.FloodWaitSeconds.Add(wait.Seconds(), worker)
+			}
+			if _, ok := input.(*tg.UploadGetFileRequest); ok {
+				metrics.GetFileCalls.Inc(worker)
+				metrics.GetFileDuration.Observe(time.Since(start).Seconds(), worker)
+				if err != nil {
+					metrics.GetFileErrors.Inc(worker)
+				}
+			}
+			return err
+		}
+	})
+}
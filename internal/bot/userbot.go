@@ -46,6 +46,15 @@ func StartUserBot(l *zap.Logger) {
 	}
 }
 
+// StopUserBot disconnects the userbot, if it was started.
+func StopUserBot() {
+	if UserBot.client == nil {
+		return
+	}
+	UserBot.client.Stop()
+	UserBot.log.Info("Userbot stopped")
+}
+
 func (u *UserBotStruct) AddBotsAsAdmins() error {
 	u.log.Info("Preparing to add bots as admins")
 	ctx := u.client.CreateContext()
@@ -2,10 +2,12 @@ package bot
 
 import (
 	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/types"
 	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -92,6 +94,58 @@ func GetNextWorker() *Worker {
 	return worker
 }
 
+// WorkersInfo describes the bots currently serving files.
+func WorkersInfo() []types.WorkerInfo {
+	Workers.mut.Lock()
+	defer Workers.mut.Unlock()
+	info := make([]types.WorkerInfo, 0, len(Workers.Bots))
+	for _, worker := range Workers.Bots {
+		info = append(info, types.WorkerInfo{
+			ID:       worker.ID,
+			UserID:   worker.Self.ID,
+			Username: worker.Self.Username,
+		})
+	}
+	return info
+}
+
+// PingWorkers pings every bot serving files, the main bot included, and
+// returns how many of them answered.
+func PingWorkers(ctx context.Context) (healthy int, total int) {
+	Workers.mut.Lock()
+	bots := append([]*Worker(nil), Workers.Bots...)
+	Workers.mut.Unlock()
+	var (
+		wg      sync.WaitGroup
+		answers int32
+	)
+	for _, worker := range bots {
+		wg.Add(1)
+		go func(worker *Worker) {
+			defer wg.Done()
+			if err := worker.Client.Ping(ctx); err == nil {
+				atomic.AddInt32(&answers, 1)
+			} else {
+				worker.log.Debug("Worker ping failed", zap.Int("worker", worker.ID), zap.Error(err))
+			}
+		}(worker)
+	}
+	wg.Wait()
+	return int(answers), len(bots)
+}
+
+// StopWorkers disconnects every bot serving files, the main bot included.
+func StopWorkers() {
+	Workers.mut.Lock()
+	defer Workers.mut.Unlock()
+	for _, worker := range Workers.Bots {
+		worker.Client.Stop()
+	}
+	if Workers.log != nil {
+		Workers.log.Info("Stopped", zap.Int("bots", len(Workers.Bots)))
+	}
+}
+
 func StartWorkers(log *zap.Logger) (*BotWorkers, error) {
 	Workers.Init(log)
 
@@ -161,7 +215,7 @@ func startWorker(l *zap.Logger, botToken string, index int) (*gotgproto.Client,
 		&gotgproto.ClientOpts{
 			Session:          sessionType,
 			DisableCopyright: true,
-			Middlewares:      GetFloodMiddleware(log.Desugar()),
+			Middlewares:      append(GetFloodMiddleware(log.Desugar()), metricsMiddleware(strconv.Itoa(index))),
 		},
 	)
 	if err != nil {
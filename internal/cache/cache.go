@@ -66,3 +66,15 @@ func (c *Cache) Delete(key string) error {
 	cache.cache.Del([]byte(key))
 	return nil
 }
+
+// Stats returns the counters of the cache.
+func (c *Cache) Stats() types.CacheStats {
+	return types.CacheStats{
+		Entries:   c.cache.EntryCount(),
+		Hits:      c.cache.HitCount(),
+		Misses:    c.cache.MissCount(),
+		HitRate:   c.cache.HitRate(),
+		Evacuated: c.cache.EvacuateCount(),
+		Expired:   c.cache.ExpiredCount(),
+	}
+}
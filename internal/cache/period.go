@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"errors"
+	"time"
+	_ "time/tzdata" // for STATS_TIMEZONE on systems without a zoneinfo database
+
+	"EverythingSuckz/fsb/internal/types"
+)
+
+// maxStatsPeriods is how many periods GetRangeStats returns at most.
+const maxStatsPeriods = 1000
+
+var (
+	ErrInvalidRange       = errors.New("the start of the range is after its end")
+	ErrInvalidGranularity = errors.New("granularity must be day, week or month")
+	ErrTooManyPeriods     = errors.New("too many periods in the range, use a larger granularity")
+)
+
+// statsLocation is STATS_TIMEZONE, where days start and end.
+var statsLocation = time.UTC
+
+// StatsLocation returns the timezone of the statistics, set by
+// STATS_TIMEZONE.
+func StatsLocation() *time.Location {
+	return statsLocation
+}
+
+// StatsDay returns the day of t in STATS_TIMEZONE. Days are stored as
+// midnight UTC of that date, so they compare as calendar days whatever the
+// timezone.
+func StatsDay(t time.Time) time.Time {
+	t = t.In(statsLocation)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// StatsToday returns the current day in STATS_TIMEZONE, see StatsDay.
+func StatsToday() time.Time {
+	return StatsDay(time.Now())
+}
+
+// ParseStatsDay parses a date in YYYY-MM-DD format as a day, see StatsDay.
+func ParseStatsDay(value string) (time.Time, error) {
+	return time.Parse("2006-01-02", value)
+}
+
+// GetPeriodStats returns the files processed and the traffic served from
+// the day from to the day to, both included.
+func (sc *StatsCache) GetPeriodStats(from, to time.Time) (types.PeriodStats, error) {
+	stats := types.PeriodStats{Start: from.Format("2006-01-02"), End: to.Format("2006-01-02")}
+	end := to.AddDate(0, 0, 1)
+	err := sc.db.Model(&types.Stats{}).
+		Select("COALESCE(SUM(file_count), 0), COALESCE(SUM(total_size), 0)").
+		Where("date >= ? AND date < ?", from, end).
+		Row().Scan(&stats.FileCount, &stats.TotalSize)
+	if err != nil {
+		return stats, err
+	}
+	stats.Traffic, err = sc.GetTrafficStats(from, end)
+	return stats, err
+}
+
+// periodBuckets are the SQL expressions that give the first day of the
+// period of a date, as YYYY-MM-DD. Weeks start on Monday.
+var periodBuckets = map[string]string{
+	types.GranularityDay:   "date(date)",
+	types.GranularityWeek:  "date(date, 'weekday 0', '-6 days')",
+	types.GranularityMonth: "strftime('%Y-%m-01', date)",
+}
+
+// GetRangeStats returns the stats of each day, week or month from the day
+// from to the day to, both included. Weeks start on Monday. The first and
+// last periods are cut to the range. Each table is read with a single
+// query grouped by period, and traffic not yet flushed isn't counted.
+func (sc *StatsCache) GetRangeStats(from, to time.Time, granularity string) ([]types.PeriodStats, error) {
+	if to.Before(from) {
+		return nil, ErrInvalidRange
+	}
+	bucket, ok := periodBuckets[granularity]
+	if !ok {
+		return nil, ErrInvalidGranularity
+	}
+
+	// the periods by the first day of their whole period
+	periods := make([]types.PeriodStats, 0)
+	index := make(map[string]int)
+	for start := from; !start.After(to); {
+		if len(periods) == maxStatsPeriods {
+			return nil, ErrTooManyPeriods
+		}
+		whole := periodStart(start, granularity)
+		next := nextPeriod(whole, granularity)
+		end := next.AddDate(0, 0, -1)
+		if end.After(to) {
+			end = to
+		}
+		index[whole.Format("2006-01-02")] = len(periods)
+		periods = append(periods, types.PeriodStats{Start: start.Format("2006-01-02"), End: end.Format("2006-01-02")})
+		start = next
+	}
+
+	end := to.AddDate(0, 0, 1)
+	var files []struct {
+		Period    string
+		FileCount int64
+		TotalSize int64
+	}
+	err := sc.db.Model(&types.Stats{}).
+		Select(bucket+" AS period, SUM(file_count) AS file_count, SUM(total_size) AS total_size").
+		Where("date >= ? AND date < ?", from, end).
+		Group("period").
+		Scan(&files).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range files {
+		if i, ok := index[row.Period]; ok {
+			periods[i].FileCount = row.FileCount
+			periods[i].TotalSize = row.TotalSize
+		}
+	}
+
+	var traffic []struct {
+		Period   string
+		Bytes    int64
+		Requests int64
+	}
+	err = sc.db.Model(&types.FileTraffic{}).
+		Select(bucket+" AS period, SUM(bytes) AS bytes, SUM(requests) AS requests").
+		Where("date >= ? AND date < ?", from, end).
+		Group("period").
+		Scan(&traffic).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range traffic {
+		if i, ok := index[row.Period]; ok {
+			periods[i].Traffic.Bytes = row.Bytes
+			periods[i].Traffic.Requests = row.Requests
+		}
+	}
+
+	var viewers []struct {
+		Period  string
+		Viewers int64
+	}
+	err = sc.db.Model(&types.TrafficViewer{}).
+		Select(bucket+" AS period, COUNT(DISTINCT viewer) AS viewers").
+		Where("date >= ? AND date < ?", from, end).
+		Group("period").
+		Scan(&viewers).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range viewers {
+		if i, ok := index[row.Period]; ok {
+			periods[i].Traffic.UniqueViewers = row.Viewers
+		}
+	}
+	return periods, nil
+}
+
+// periodStart returns the first day of the whole period of day.
+func periodStart(day time.Time, granularity string) time.Time {
+	switch granularity {
+	case types.GranularityWeek:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case types.GranularityMonth:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+// nextPeriod returns the first day of the period after the one starting on
+// start.
+func nextPeriod(start time.Time, granularity string) time.Time {
+	switch granularity {
+	case types.GranularityWeek:
+		return start.AddDate(0, 0, 7)
+	case types.GranularityMonth:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func testDay(t *testing.T, value string) time.Time {
+	t.Helper()
+	day, err := ParseStatsDay(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return day
+}
+
+// newTestStatsCache returns a StatsCache on an in-memory database with a
+// few files and traffic around the end of February 2024, a leap year.
+func newTestStatsCache(t *testing.T) *StatsCache {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&types.Stats{}, &types.FileTraffic{}, &types.TrafficViewer{}); err != nil {
+		t.Fatal(err)
+	}
+	// Tuesday 2024-02-27 and Wednesday 2024-03-13 are outside the ranges
+	// the tests ask for
+	for _, row := range []struct {
+		day   string
+		files int64
+	}{
+		{"2024-02-27", 100},
+		{"2024-02-28", 1},
+		{"2024-03-03", 2},
+		{"2024-03-04", 4},
+		{"2024-03-12", 8},
+		{"2024-03-13", 100},
+	} {
+		day := testDay(t, row.day)
+		if err := db.Create(&types.Stats{Date: day, FileCount: row.files, TotalSize: row.files * 10}).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Create(&types.FileTraffic{MessageID: 1, Date: day, Bytes: row.files * 100, Requests: row.files}).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Create(&types.TrafficViewer{MessageID: 1, Date: day, Viewer: "a"}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a second viewer on Monday 2024-03-04 only
+	if err := db.Create(&types.TrafficViewer{MessageID: 1, Date: testDay(t, "2024-03-04"), Viewer: "b"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &StatsCache{db: db, log: zap.NewNop()}
+}
+
+func period(start, end string, files, viewers int64) types.PeriodStats {
+	return types.PeriodStats{
+		Start:     start,
+		End:       end,
+		FileCount: files,
+		TotalSize: files * 10,
+		Traffic:   types.TrafficStats{Bytes: files * 100, Requests: files, UniqueViewers: viewers},
+	}
+}
+
+func TestGetRangeStats(t *testing.T) {
+	sc := newTestStatsCache(t)
+	tests := []struct {
+		name        string
+		from, to    string
+		granularity string
+		want        []types.PeriodStats
+	}{
+		{
+			name: "weeks cut to the range", from: "2024-02-28", to: "2024-03-12", granularity: types.GranularityWeek,
+			want: []types.PeriodStats{
+				period("2024-02-28", "2024-03-03", 3, 1),
+				period("2024-03-04", "2024-03-10", 4, 2),
+				period("2024-03-11", "2024-03-12", 8, 1),
+			},
+		},
+		{
+			name: "week starting on Monday", from: "2024-03-04", to: "2024-03-10", granularity: types.GranularityWeek,
+			want: []types.PeriodStats{period("2024-03-04", "2024-03-10", 4, 2)},
+		},
+		{
+			name: "week ending on Sunday", from: "2024-03-03", to: "2024-03-03", granularity: types.GranularityWeek,
+			want: []types.PeriodStats{period("2024-03-03", "2024-03-03", 2, 1)},
+		},
+		{
+			name: "months across a leap day", from: "2024-02-28", to: "2024-03-12", granularity: types.GranularityMonth,
+			want: []types.PeriodStats{
+				period("2024-02-28", "2024-02-29", 1, 1),
+				period("2024-03-01", "2024-03-12", 14, 2),
+			},
+		},
+		{
+			name: "whole months", from: "2024-02-01", to: "2024-03-31", granularity: types.GranularityMonth,
+			want: []types.PeriodStats{
+				period("2024-02-01", "2024-02-29", 101, 1),
+				period("2024-03-01", "2024-03-31", 114, 2),
+			},
+		},
+		{
+			name: "days", from: "2024-03-03", to: "2024-03-05", granularity: types.GranularityDay,
+			want: []types.PeriodStats{
+				period("2024-03-03", "2024-03-03", 2, 1),
+				period("2024-03-04", "2024-03-04", 4, 2),
+				period("2024-03-05", "2024-03-05", 0, 0),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sc.GetRangeStats(testDay(t, tt.from), testDay(t, tt.to), tt.granularity)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetRangeStats() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRangeStatsErrors(t *testing.T) {
+	sc := newTestStatsCache(t)
+	tests := []struct {
+		name        string
+		from, to    string
+		granularity string
+		want        error
+	}{
+		{name: "reversed range", from: "2024-03-02", to: "2024-03-01", granularity: types.GranularityDay, want: ErrInvalidRange},
+		{name: "unknown granularity", from: "2024-03-01", to: "2024-03-02", granularity: "year", want: ErrInvalidGranularity},
+		{name: "too many days", from: "2020-01-01", to: "2024-01-01", granularity: types.GranularityDay, want: ErrTooManyPeriods},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sc.GetRangeStats(testDay(t, tt.from), testDay(t, tt.to), tt.granularity)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("GetRangeStats() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
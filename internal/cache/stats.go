@@ -2,8 +2,10 @@ package cache
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
+	"EverythingSuckz/fsb/config"
 	"EverythingSuckz/fsb/internal/database"
 	"EverythingSuckz/fsb/internal/types"
 
@@ -13,8 +15,14 @@ import (
 )
 
 type StatsCache struct {
-	db  *gorm.DB
-	log *zap.Logger
+	db      *gorm.DB
+	log     *zap.Logger
+	traffic *trafficBuffer
+
+	// recent is the last result of GetCompleteStats served by GetRecentStats
+	recentMu sync.Mutex
+	recent   *types.StatisticsResponse
+	recentAt time.Time
 }
 
 var statsCache *StatsCache
@@ -28,10 +36,19 @@ func InitStatsCache(log *zap.Logger) {
 		return
 	}
 	
+	loc, err := time.LoadLocation(config.ValueOf.StatsTimezone)
+	if err != nil {
+		log.Error("Invalid STATS_TIMEZONE, using UTC", zap.Error(err))
+		loc = time.UTC
+	}
+	statsLocation = loc
+
 	statsCache = &StatsCache{
-		db:  db,
-		log: log,
+		db:      db,
+		log:     log,
+		traffic: newTrafficBuffer(),
 	}
+	go statsCache.flushTrafficLoop()
 }
 
 func GetStatsCache() *StatsCache {
@@ -41,7 +58,7 @@ func GetStatsCache() *StatsCache {
 func (sc *StatsCache) RecordFileProcessed(fileSize int64) error {
 	if sc == nil || sc.db == nil { return fmt.Errorf("cache uninitialized") }
 
-	today := time.Now().Truncate(24 * time.Hour)
+	today := StatsToday()
 
 	return sc.db.Clauses(clause.OnConflict{
 		Columns: []clause.Column{{Name: "date"}},
@@ -57,7 +74,7 @@ func (sc *StatsCache) RecordFileProcessed(fileSize int64) error {
 }
 
 func (sc *StatsCache) GetTodayStats() (types.DailyStats, error) {
-	today := time.Now().Truncate(24 * time.Hour)
+	today := StatsToday()
 	var stats types.Stats
 	err := sc.db.Where("date = ?", today).First(&stats).Error
 	if err == gorm.ErrRecordNotFound {
@@ -67,7 +84,7 @@ func (sc *StatsCache) GetTodayStats() (types.DailyStats, error) {
 }
 
 func (sc *StatsCache) GetYesterdayStats() (types.DailyStats, error) {
-	yesterday := time.Now().AddDate(0, 0, -1).Truncate(24 * time.Hour)
+	yesterday := StatsToday().AddDate(0, 0, -1)
 	var stats types.Stats
 	err := sc.db.Where("date = ?", yesterday).First(&stats).Error
 	if err == gorm.ErrRecordNotFound {
@@ -77,7 +94,7 @@ func (sc *StatsCache) GetYesterdayStats() (types.DailyStats, error) {
 }
 
 func (sc *StatsCache) GetLastWeekStats() (types.WeeklyStats, error) {
-	endDate := time.Now().Truncate(24 * time.Hour)
+	endDate := StatsToday()
 	startDate := endDate.AddDate(0, 0, -7)
 	var result struct { FileCount int64; TotalSize int64 }
 	err := sc.db.Model(&types.Stats{}).
@@ -97,9 +114,36 @@ func (sc *StatsCache) GetTotalStats() (types.DailyStats, error) {
 }
 
 func (sc *StatsCache) GetCompleteStats() (types.StatisticsResponse, error) {
+	if err := sc.FlushTraffic(); err != nil {
+		sc.log.Error("Failed to save traffic stats", zap.Error(err))
+	}
 	today, _ := sc.GetTodayStats()
 	yesterday, _ := sc.GetYesterdayStats()
 	lastWeek, _ := sc.GetLastWeekStats()
 	total, _ := sc.GetTotalStats()
-	return types.StatisticsResponse{Today: today, Yesterday: yesterday, LastWeek: lastWeek, Total: total}, nil
+
+	tomorrow := today.Date.AddDate(0, 0, 1)
+	today.Traffic, _ = sc.GetTrafficStats(today.Date, tomorrow)
+	yesterday.Traffic, _ = sc.GetTrafficStats(yesterday.Date, today.Date)
+	lastWeek.Traffic, _ = sc.GetTrafficStats(lastWeek.StartDate, lastWeek.EndDate)
+	total.Traffic, _ = sc.GetTrafficStats(time.Time{}, tomorrow)
+	topFiles, _ := sc.GetTopFiles(tomorrow.AddDate(0, 0, -7), 5)
+	return types.StatisticsResponse{Today: today, Yesterday: yesterday, LastWeek: lastWeek, Total: total, TopFiles: topFiles}, nil
+}
+
+// GetRecentStats returns the result of GetCompleteStats, computed at most
+// once every trafficFlushInterval, for callers that anyone can reach.
+func (sc *StatsCache) GetRecentStats() (types.StatisticsResponse, error) {
+	sc.recentMu.Lock()
+	defer sc.recentMu.Unlock()
+	if sc.recent != nil && time.Since(sc.recentAt) < trafficFlushInterval {
+		return *sc.recent, nil
+	}
+	stats, err := sc.GetCompleteStats()
+	if err != nil {
+		return stats, err
+	}
+	sc.recent = &stats
+	sc.recentAt = time.Now()
+	return stats, nil
 }
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"testing"
+	"time"
+)
+
+func TestGetRecentStats(t *testing.T) {
+	sc := newTestStatsCache(t)
+	sc.traffic = newTrafficBuffer()
+	first, err := sc.GetRecentStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.db.Create(&types.Stats{Date: StatsToday(), FileCount: 1000}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	cached, err := sc.GetRecentStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached.Total.FileCount != first.Total.FileCount {
+		t.Errorf("total file count = %d within the interval, want the cached %d", cached.Total.FileCount, first.Total.FileCount)
+	}
+
+	sc.recentAt = time.Now().Add(-trafficFlushInterval)
+	fresh, err := sc.GetRecentStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := first.Total.FileCount + 1000; fresh.Total.FileCount != want {
+		t.Errorf("total file count = %d after the interval, want %d", fresh.Total.FileCount, want)
+	}
+}
+
+func TestStatisticsResponsePublic(t *testing.T) {
+	traffic := types.TrafficStats{Bytes: 100, Requests: 2, UniqueViewers: 1}
+	stats := types.StatisticsResponse{TopFiles: []types.FileTrafficStats{{MessageID: 52311, TrafficStats: traffic}}}
+	public := stats.Public()
+	if len(public.TopFiles) != 1 || public.TopFiles[0] != traffic {
+		t.Errorf("top files = %+v, want [%+v]", public.TopFiles, traffic)
+	}
+}
@@ -0,0 +1,318 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/types"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// trafficFlushInterval is how often the traffic counted in memory is
+// written to the database.
+const trafficFlushInterval = 30 * time.Second
+
+// TrafficHit is one request for a file.
+type TrafficHit struct {
+	MessageID int
+	Bytes     int64
+	Viewer    string // see utils.HashIP
+	Referrer  string // host of the referring page, "" if none
+	Country   string // see utils.CountryOf, "" if unknown
+}
+
+type trafficKey struct {
+	messageID int
+	date      time.Time
+}
+
+type sourceKey struct {
+	trafficKey
+	kind  string
+	value string
+}
+
+// trafficBuffer holds the traffic not yet written to the database, so a
+// request costs a map update instead of a write.
+type trafficBuffer struct {
+	mu      sync.Mutex
+	files   map[trafficKey]*types.FileTraffic
+	viewers map[types.TrafficViewer]struct{}
+	sources map[sourceKey]int64
+}
+
+func newTrafficBuffer() *trafficBuffer {
+	return &trafficBuffer{
+		files:   make(map[trafficKey]*types.FileTraffic),
+		viewers: make(map[types.TrafficViewer]struct{}),
+		sources: make(map[sourceKey]int64),
+	}
+}
+
+// addSource counts a request from a referrer or country.
+func (b *trafficBuffer) addSource(key trafficKey, kind, value string, requests int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sources[sourceKey{trafficKey: key, kind: kind, value: value}] += requests
+}
+
+// add adds the counts of file, if not nil, and the viewers to the buffer.
+func (b *trafficBuffer) add(file *types.FileTraffic, viewers ...types.TrafficViewer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if file != nil {
+		key := trafficKey{messageID: file.MessageID, date: file.Date}
+		if pending, ok := b.files[key]; ok {
+			pending.Bytes += file.Bytes
+			pending.Requests += file.Requests
+		} else {
+			b.files[key] = file
+		}
+	}
+	for _, viewer := range viewers {
+		b.viewers[viewer] = struct{}{}
+	}
+}
+
+// take empties the buffer and returns what it held.
+func (b *trafficBuffer) take() ([]*types.FileTraffic, []types.TrafficViewer, []types.TrafficSource) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	files := make([]*types.FileTraffic, 0, len(b.files))
+	for _, file := range b.files {
+		files = append(files, file)
+	}
+	viewers := make([]types.TrafficViewer, 0, len(b.viewers))
+	for viewer := range b.viewers {
+		viewers = append(viewers, viewer)
+	}
+	sources := make([]types.TrafficSource, 0, len(b.sources))
+	for key, requests := range b.sources {
+		sources = append(sources, types.TrafficSource{
+			MessageID: key.messageID,
+			Date:      key.date,
+			Kind:      key.kind,
+			Value:     key.value,
+			Requests:  requests,
+		})
+	}
+	b.files = make(map[trafficKey]*types.FileTraffic)
+	b.viewers = make(map[types.TrafficViewer]struct{})
+	b.sources = make(map[sourceKey]int64)
+	return files, viewers, sources
+}
+
+// RecordTraffic counts a request for a file and the bytes sent in response.
+// The counts are written to the database every 30 seconds.
+func (sc *StatsCache) RecordTraffic(hit TrafficHit) {
+	if sc == nil {
+		return
+	}
+	today := StatsToday()
+	file := &types.FileTraffic{MessageID: hit.MessageID, Date: today, Bytes: hit.Bytes, Requests: 1}
+	if hit.Viewer == "" {
+		sc.traffic.add(file)
+	} else {
+		sc.traffic.add(file, types.TrafficViewer{MessageID: hit.MessageID, Date: today, Viewer: hit.Viewer})
+	}
+	key := trafficKey{messageID: hit.MessageID, date: today}
+	if hit.Referrer != "" {
+		sc.traffic.addSource(key, types.TrafficReferrer, hit.Referrer, 1)
+	}
+	if hit.Country != "" {
+		sc.traffic.addSource(key, types.TrafficCountry, hit.Country, 1)
+	}
+}
+
+// FlushTraffic writes the traffic counted since the last flush.
+func (sc *StatsCache) FlushTraffic() error {
+	if sc == nil {
+		return nil
+	}
+	files, viewers, sources := sc.traffic.take()
+	if len(files) == 0 && len(viewers) == 0 && len(sources) == 0 {
+		return nil
+	}
+	err := sc.db.Transaction(func(tx *gorm.DB) error {
+		for _, file := range files {
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "message_id"}, {Name: "date"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"bytes":    gorm.Expr("bytes + ?", file.Bytes),
+					"requests": gorm.Expr("requests + ?", file.Requests),
+				}),
+			}).Create(file).Error
+			if err != nil {
+				return err
+			}
+		}
+		for _, source := range sources {
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "message_id"}, {Name: "date"}, {Name: "kind"}, {Name: "value"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"requests": gorm.Expr("requests + ?", source.Requests),
+				}),
+			}).Create(&source).Error
+			if err != nil {
+				return err
+			}
+		}
+		if len(viewers) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(viewers, 100).Error
+	})
+	if err != nil {
+		// keep the counts for the next flush
+		for _, file := range files {
+			sc.traffic.add(file)
+		}
+		sc.traffic.add(nil, viewers...)
+		for _, source := range sources {
+			sc.traffic.addSource(trafficKey{messageID: source.MessageID, date: source.Date}, source.Kind, source.Value, source.Requests)
+		}
+	}
+	return err
+}
+
+func (sc *StatsCache) flushTrafficLoop() {
+	ticker := time.NewTicker(trafficFlushInterval)
+	defer ticker.Stop()
+	var lastPrune time.Time
+	for range ticker.C {
+		if err := sc.FlushTraffic(); err != nil {
+			sc.log.Error("Failed to save traffic stats", zap.Error(err))
+		}
+		if time.Since(lastPrune) >= 24*time.Hour {
+			if err := sc.pruneTraffic(); err != nil {
+				sc.log.Error("Failed to delete old traffic stats", zap.Error(err))
+			}
+			lastPrune = time.Now()
+		}
+	}
+}
+
+// trafficSince returns the first day whose viewers, referrers and
+// countries are kept, or the zero time if they are kept forever.
+func trafficSince() time.Time {
+	if config.ValueOf.StatsRetention <= 0 {
+		return time.Time{}
+	}
+	return StatsToday().AddDate(0, 0, 1-config.ValueOf.StatsRetention)
+}
+
+// pruneTraffic deletes the viewers, referrers and countries older than
+// STATS_RETENTION_DAYS. The byte and request counts are kept.
+func (sc *StatsCache) pruneTraffic() error {
+	since := trafficSince()
+	if since.IsZero() {
+		return nil
+	}
+	if err := sc.db.Where("date < ?", since).Delete(&types.TrafficViewer{}).Error; err != nil {
+		return err
+	}
+	return sc.db.Where("date < ?", since).Delete(&types.TrafficSource{}).Error
+}
+
+// GetTrafficStats returns the traffic served from the day of from up to,
+// but not including, the day of to. A zero from means since the start.
+func (sc *StatsCache) GetTrafficStats(from, to time.Time) (types.TrafficStats, error) {
+	var stats types.TrafficStats
+	query := sc.db.Model(&types.FileTraffic{}).Where("date < ?", to)
+	if !from.IsZero() {
+		query = query.Where("date >= ?", from)
+	}
+	err := query.Select("COALESCE(SUM(bytes), 0), COALESCE(SUM(requests), 0)").
+		Row().Scan(&stats.Bytes, &stats.Requests)
+	if err != nil {
+		return stats, err
+	}
+	query = sc.db.Model(&types.TrafficViewer{}).Where("date < ?", to)
+	if !from.IsZero() {
+		query = query.Where("date >= ?", from)
+	}
+	err = query.Distinct("viewer").Count(&stats.UniqueViewers).Error
+	return stats, err
+}
+
+// GetTopFiles returns the files that served the most bytes from the day of
+// from, most served first.
+func (sc *StatsCache) GetTopFiles(from time.Time, limit int) ([]types.FileTrafficStats, error) {
+	var files []types.FileTrafficStats
+	err := sc.db.Model(&types.FileTraffic{}).
+		Select("message_id, SUM(bytes) AS bytes, SUM(requests) AS requests").
+		Where("date >= ?", from).
+		Group("message_id").
+		Order("bytes DESC").
+		Limit(limit).
+		Scan(&files).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range files {
+		err := sc.db.Model(&types.TrafficViewer{}).
+			Where("message_id = ? AND date >= ?", files[i].MessageID, from).
+			Distinct("viewer").
+			Count(&files[i].UniqueViewers).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// GetLinkStats returns the views of a file over the days kept by
+// STATS_RETENTION_DAYS, with its top referrers and countries.
+func (sc *StatsCache) GetLinkStats(messageID int) (types.LinkStats, error) {
+	if err := sc.FlushTraffic(); err != nil {
+		sc.log.Error("Failed to save traffic stats", zap.Error(err))
+	}
+	stats := types.LinkStats{MessageID: messageID, Since: trafficSince()}
+	tomorrow := StatsToday().AddDate(0, 0, 1)
+	err := sc.db.Model(&types.FileTraffic{}).
+		Select("date, bytes, requests").
+		Where("message_id = ? AND date >= ?", messageID, stats.Since).
+		Order("date DESC").
+		Scan(&stats.Days).Error
+	if err != nil {
+		return stats, err
+	}
+	for i, day := range stats.Days {
+		err := sc.db.Model(&types.TrafficViewer{}).
+			Where("message_id = ? AND date = ?", messageID, day.Date).
+			Count(&stats.Days[i].UniqueViewers).Error
+		if err != nil {
+			return stats, err
+		}
+		stats.Total.Bytes += day.Bytes
+		stats.Total.Requests += day.Requests
+	}
+	err = sc.db.Model(&types.TrafficViewer{}).
+		Where("message_id = ? AND date >= ? AND date < ?", messageID, stats.Since, tomorrow).
+		Distinct("viewer").
+		Count(&stats.Total.UniqueViewers).Error
+	if err != nil {
+		return stats, err
+	}
+	if stats.Referrers, err = sc.topSources(messageID, types.TrafficReferrer, stats.Since); err != nil {
+		return stats, err
+	}
+	stats.Countries, err = sc.topSources(messageID, types.TrafficCountry, stats.Since)
+	return stats, err
+}
+
+func (sc *StatsCache) topSources(messageID int, kind string, since time.Time) ([]types.SourceCount, error) {
+	sources := []types.SourceCount{}
+	err := sc.db.Model(&types.TrafficSource{}).
+		Select("value, SUM(requests) AS requests").
+		Where("message_id = ? AND kind = ? AND date >= ?", messageID, kind, since).
+		Group("value").
+		Order("requests DESC").
+		Limit(10).
+		Scan(&sources).Error
+	return sources, err
+}
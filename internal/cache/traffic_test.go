@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"testing"
+)
+
+// fileTraffic returns the bytes and requests written for a file, and its
+// viewers and referrers.
+func fileTraffic(t *testing.T, sc *StatsCache, messageID int) (bytes, requests, viewers, referrers int64) {
+	t.Helper()
+	var traffic types.FileTraffic
+	if err := sc.db.Where("message_id = ?", messageID).Limit(1).Find(&traffic).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.db.Model(&types.TrafficViewer{}).Where("message_id = ?", messageID).Count(&viewers).Error; err != nil {
+		t.Fatal(err)
+	}
+	if sc.db.Migrator().HasTable(&types.TrafficSource{}) {
+		err := sc.db.Model(&types.TrafficSource{}).Select("COALESCE(SUM(requests), 0)").
+			Where("message_id = ? AND kind = ?", messageID, types.TrafficReferrer).Row().Scan(&referrers)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return traffic.Bytes, traffic.Requests, viewers, referrers
+}
+
+func TestFlushTrafficRetry(t *testing.T) {
+	sc := newTestStatsCache(t)
+	sc.traffic = newTrafficBuffer()
+	sc.RecordTraffic(TrafficHit{MessageID: 7, Bytes: 100, Viewer: "a", Referrer: "example.com"})
+	sc.RecordTraffic(TrafficHit{MessageID: 7, Bytes: 50, Viewer: "b"})
+
+	// the referrer can't be written, so nothing of the flush is
+	if err := sc.FlushTraffic(); err == nil {
+		t.Fatal("FlushTraffic without the sources table = nil, want an error")
+	}
+	if bytes, requests, viewers, _ := fileTraffic(t, sc, 7); bytes != 0 || requests != 0 || viewers != 0 {
+		t.Errorf("after the failed flush: %d bytes, %d requests, %d viewers written, want none", bytes, requests, viewers)
+	}
+
+	// the counts are kept and merged with the requests made in between
+	sc.RecordTraffic(TrafficHit{MessageID: 7, Bytes: 25, Viewer: "a", Referrer: "example.com"})
+	if err := sc.db.AutoMigrate(&types.TrafficSource{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.FlushTraffic(); err != nil {
+		t.Fatal(err)
+	}
+	bytes, requests, viewers, referrers := fileTraffic(t, sc, 7)
+	if bytes != 175 || requests != 3 || viewers != 2 || referrers != 2 {
+		t.Errorf("after the retry: %d bytes, %d requests, %d viewers, %d referrer requests; want 175, 3, 2, 2",
+			bytes, requests, viewers, referrers)
+	}
+
+	// a flush with nothing pending writes nothing
+	if err := sc.FlushTraffic(); err != nil {
+		t.Fatal(err)
+	}
+	if again, _, _, _ := fileTraffic(t, sc, 7); again != bytes {
+		t.Errorf("bytes after an empty flush = %d, want %d", again, bytes)
+	}
+}
+
+func TestFlushTrafficAddsUp(t *testing.T) {
+	sc := newTestStatsCache(t)
+	sc.traffic = newTrafficBuffer()
+	if err := sc.db.AutoMigrate(&types.TrafficSource{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		sc.RecordTraffic(TrafficHit{MessageID: 7, Bytes: 10, Viewer: "a"})
+		if err := sc.FlushTraffic(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the second flush adds to the day row instead of replacing it, and the
+	// viewer is only counted once
+	if bytes, requests, viewers, _ := fileTraffic(t, sc, 7); bytes != 20 || requests != 2 || viewers != 1 {
+		t.Errorf("%d bytes, %d requests, %d viewers; want 20, 2, 1", bytes, requests, viewers)
+	}
+}
@@ -0,0 +1,269 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/cache"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"strconv"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"go.uber.org/zap"
+)
+
+const topUsersLimit = 10
+
+// WorkersInfo lists the bots serving files. It is set by the bot package,
+// which imports this one.
+var WorkersInfo func() []types.WorkerInfo
+
+func (m *command) LoadAdmin(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("admin")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("ban", m.adminOnly("ban", m.ban)))
+	dispatcher.AddHandler(handlers.NewCommand("unban", m.adminOnly("unban", m.unban)))
+	dispatcher.AddHandler(handlers.NewCommand("banlist", m.adminOnly("banlist", m.banList)))
+	dispatcher.AddHandler(handlers.NewCommand("allow", m.adminOnly("allow", m.allow)))
+	dispatcher.AddHandler(handlers.NewCommand("disallow", m.adminOnly("disallow", m.disallow)))
+	dispatcher.AddHandler(handlers.NewCommand("allowlist", m.adminOnly("allowlist", m.allowList)))
+	dispatcher.AddHandler(handlers.NewCommand("users", m.adminOnly("users", m.users)))
+	dispatcher.AddHandler(handlers.NewCommand("workers", m.adminOnly("workers", workers)))
+	dispatcher.AddHandler(handlers.NewCommand("cachestats", m.adminOnly("cachestats", cacheStats)))
+	dispatcher.AddHandler(handlers.NewCommand("purge", m.adminOnly("purge", m.purge)))
+	dispatcher.AddHandler(handlers.NewCommand("config", m.adminOnly("config", showConfig)))
+}
+
+// isAdmin reports whether the user is listed in ADMIN_IDS.
+func isAdmin(userID int64) bool {
+	return utils.Contains(config.ValueOf.AdminIDs, userID)
+}
+
+// adminOnly wraps the handler of an admin command: other users get the
+// "admin_only" refusal, and every use by an admin is audit-logged.
+func (m *command) adminOnly(action string, handler handlers.CallbackResponse) handlers.CallbackResponse {
+	log := m.log.Named("audit")
+	return func(ctx *ext.Context, u *ext.Update) error {
+		userID := senderID(u)
+		args := strings.Join(u.Args()[1:], " ")
+		if !isAdmin(userID) {
+			log.Warn("Refused admin command", zap.String("action", action), zap.Int64("userID", userID))
+			reply(ctx, u, "admin_only", nil, nil)
+			return dispatcher.EndGroups
+		}
+		log.Info("Admin action", zap.String("action", action), zap.String("args", args), zap.Int64("adminID", userID))
+		if err := database.AddAuditLog(&types.AuditLog{AdminID: userID, Action: action, Args: args}); err != nil {
+			log.Error("Failed to write audit log", zap.Error(err))
+		}
+		return handler(ctx, u)
+	}
+}
+
+// ban adds a user to the ban list: /ban <userID> [reason]
+func (m *command) ban(ctx *ext.Context, u *ext.Update) error {
+	args := u.Args()
+	userID, err := userIDArg(args)
+	if err != nil {
+		reply(ctx, u, "ban_usage", nil, nil)
+		return dispatcher.EndGroups
+	}
+	if isAdmin(userID) {
+		reply(ctx, u, "ban_admin", nil, nil)
+		return dispatcher.EndGroups
+	}
+	ban := &types.BannedUser{
+		UserID:   userID,
+		Reason:   strings.Join(args[2:], " "),
+		BannedBy: senderID(u),
+	}
+	if err := database.BanUser(ban); err != nil {
+		m.log.Error("Failed to ban user", zap.Error(err))
+		reply(ctx, u, "admin_failed", nil, nil)
+		return dispatcher.EndGroups
+	}
+	reply(ctx, u, "ban_done", ban, nil)
+	return dispatcher.EndGroups
+}
+
+// unban removes a user from the ban list: /unban <userID>
+func (m *command) unban(ctx *ext.Context, u *ext.Update) error {
+	userID, err := userIDArg(u.Args())
+	if err != nil {
+		reply(ctx, u, "unban_usage", nil, nil)
+		return dispatcher.EndGroups
+	}
+	banned, err := database.UnbanUser(userID)
+	if err != nil {
+		m.log.Error("Failed to unban user", zap.Error(err))
+		reply(ctx, u, "admin_failed", nil, nil)
+		return dispatcher.EndGroups
+	}
+	data := map[string]interface{}{"UserID": userID}
+	if !banned {
+		reply(ctx, u, "unban_missing", data, nil)
+		return dispatcher.EndGroups
+	}
+	reply(ctx, u, "unban_done", data, nil)
+	return dispatcher.EndGroups
+}
+
+// banList shows the banned users.
+func (m *command) banList(ctx *ext.Context, u *ext.Update) error {
+	banned, err := database.GetBannedUsers()
+	if err != nil {
+		m.log.Error("Failed to get ban list", zap.Error(err))
+		reply(ctx, u, "admin_failed", nil, nil)
+		return dispatcher.EndGroups
+	}
+	reply(ctx, u, "banlist", map[string]interface{}{"Users": banned}, nil)
+	return dispatcher.EndGroups
+}
+
+// allow adds a user to the allow list: /allow <userID>
+// Once the list has a user, only the users in it (and in ALLOWED_USERS) can use the bot.
+func (m *command) allow(ctx *ext.Context, u *ext.Update) error {
+	userID, err := userIDArg(u.Args())
+	if err != nil {
+		reply(ctx, u, "allow_usage", nil, nil)
+		return dispatcher.EndGroups
+	}
+	if err := database.AllowUser(&types.AllowedUser{UserID: userID, AddedBy: senderID(u)}); err != nil {
+		m.log.Error("Failed to allow user", zap.Error(err))
+		reply(ctx, u, "admin_failed", nil, nil)
+		return dispatcher.EndGroups
+	}
+	reply(ctx, u, "allow_done", map[string]interface{}{"UserID": userID}, nil)
+	return dispatcher.EndGroups
+}
+
+// disallow removes a user from the allow list: /disallow <userID>
+func (m *command) disallow(ctx *ext.Context, u *ext.Update) error {
+	userID, err := userIDArg(u.Args())
+	if err != nil {
+		reply(ctx, u, "disallow_usage", nil, nil)
+		return dispatcher.EndGroups
+	}
+	allowed, err := database.DisallowUser(userID)
+	if err != nil {
+		m.log.Error("Failed to disallow user", zap.Error(err))
+		reply(ctx, u, "admin_failed", nil, nil)
+		return dispatcher.EndGroups
+	}
+	data := map[string]interface{}{
+		"UserID": userID,
+		"InEnv":  utils.Contains(config.ValueOf.AllowedUsers, userID),
+	}
+	if !allowed {
+		reply(ctx, u, "disallow_missing", data, nil)
+		return dispatcher.EndGroups
+	}
+	reply(ctx, u, "disallow_done", data, nil)
+	return dispatcher.EndGroups
+}
+
+// allowList shows the users in ALLOWED_USERS and in the allow list.
+func (m *command) allowList(ctx *ext.Context, u *ext.Update) error {
+	allowed, err := database.GetAllowedUsers()
+	if err != nil {
+		m.log.Error("Failed to get allow list", zap.Error(err))
+		reply(ctx, u, "admin_failed", nil, nil)
+		return dispatcher.EndGroups
+	}
+	reply(ctx, u, "allowlist", map[string]interface{}{
+		"Env":   config.ValueOf.AllowedUsers,
+		"Users": allowed,
+	}, nil)
+	return dispatcher.EndGroups
+}
+
+// users shows how many users the bot has and who uses it the most.
+func (m *command) users(ctx *ext.Context, u *ext.Update) error {
+	total, err := database.CountUsers()
+	if err != nil {
+		m.log.Error("Failed to count users", zap.Error(err))
+		reply(ctx, u, "admin_failed", nil, nil)
+		return dispatcher.EndGroups
+	}
+	banned, _ := database.CountBannedUsers()
+	usage, err := database.TopUsers(topUsersLimit)
+	if err != nil {
+		m.log.Error("Failed to get top users", zap.Error(err))
+	}
+	top := make([]map[string]interface{}, 0, len(usage))
+	for _, user := range usage {
+		top = append(top, map[string]interface{}{
+			"UserID": user.OwnerID,
+			"Files":  user.Files,
+			"Size":   utils.FormatFileSizeShort(user.Bytes),
+		})
+	}
+	reply(ctx, u, "users", map[string]interface{}{
+		"Total":  total,
+		"Banned": banned,
+		"Top":    top,
+	}, nil)
+	return dispatcher.EndGroups
+}
+
+func workers(ctx *ext.Context, u *ext.Update) error {
+	var list []types.WorkerInfo
+	if WorkersInfo != nil {
+		list = WorkersInfo()
+	}
+	reply(ctx, u, "workers", map[string]interface{}{"Workers": list}, nil)
+	return dispatcher.EndGroups
+}
+
+func cacheStats(ctx *ext.Context, u *ext.Update) error {
+	stats := cache.GetCache().Stats()
+	reply(ctx, u, "cachestats", map[string]interface{}{
+		"Stats":   stats,
+		"HitRate": strconv.FormatFloat(stats.HitRate*100, 'f', 1, 64),
+	}, nil)
+	return dispatcher.EndGroups
+}
+
+// purge deletes a file from the log channel, revokes its links and drops its
+// cached properties: /purge <msgID>
+func (m *command) purge(ctx *ext.Context, u *ext.Update) error {
+	args := u.Args()
+	if len(args) < 2 {
+		reply(ctx, u, "purge_usage", nil, nil)
+		return dispatcher.EndGroups
+	}
+	messageID, err := strconv.Atoi(args[1])
+	if err != nil {
+		reply(ctx, u, "purge_usage", nil, nil)
+		return dispatcher.EndGroups
+	}
+	if err := database.RevokeUserFile(messageID); err != nil {
+		m.log.Error("Failed to revoke link", zap.Error(err))
+	}
+	if err := deleteFromLogChannel(ctx, messageID); err != nil {
+		m.log.Error("Failed to delete file from log channel", zap.Error(err), zap.Int("messageID", messageID))
+		reply(ctx, u, "admin_failed", nil, nil)
+		return dispatcher.EndGroups
+	}
+	if WorkersInfo != nil {
+		for _, worker := range WorkersInfo() {
+			utils.ForgetFile(messageID, worker.UserID)
+		}
+	}
+	reply(ctx, u, "purge_done", map[string]interface{}{"MessageID": messageID}, nil)
+	return dispatcher.EndGroups
+}
+
+func showConfig(ctx *ext.Context, u *ext.Update) error {
+	reply(ctx, u, "config", map[string]interface{}{"Lines": config.ValueOf.Redacted()}, nil)
+	return dispatcher.EndGroups
+}
+
+func userIDArg(args []string) (int64, error) {
+	if len(args) < 2 {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.ParseInt(args[1], 10, 64)
+}
@@ -0,0 +1,275 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/templates"
+	"EverythingSuckz/fsb/internal/types"
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// broadcastProgressInterval is how often the status message of a broadcast
+// is updated.
+const broadcastProgressInterval = 5 * time.Second
+
+// broadcastJob is a message to copy to every active user, from the chat of
+// the admin who sent /broadcast.
+type broadcastJob struct {
+	chat      tg.InputPeerClass
+	messageID int
+	statusID  int // message showing the progress to the admin
+	lang      string
+}
+
+// broadcastReport is what the "broadcast_progress" and "broadcast_done"
+// templates are executed with.
+type broadcastReport struct {
+	Total     int
+	Done      int
+	Delivered int
+	Failed    int
+	Blocked   int // users marked inactive, also counted in Failed
+}
+
+// broadcastStopTimeout is how long the status messages of the stopped
+// broadcasts can take to be updated on shutdown.
+const broadcastStopTimeout = 5 * time.Second
+
+var (
+	broadcastQueue = make(chan *broadcastJob, 16)
+	broadcastOnce  sync.Once
+	// broadcastCtx is cancelled by StopBroadcasts, and broadcastStopped is
+	// closed once the worker has returned.
+	broadcastCtx, cancelBroadcasts = context.WithCancel(context.Background())
+	broadcastStopped               = make(chan struct{})
+)
+
+// StopBroadcasts cancels the running and queued broadcasts, marking their
+// status messages as stopped, and waits for the worker to return. It's
+// called on shutdown before the bots are stopped.
+func StopBroadcasts() {
+	cancelBroadcasts()
+	// if the worker never started, closing here keeps it from starting
+	broadcastOnce.Do(func() { close(broadcastStopped) })
+	<-broadcastStopped
+}
+
+func (m *command) LoadBroadcast(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("broadcast")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("broadcast", m.adminOnly("broadcast", m.broadcast)))
+}
+
+// recordUser adds the user of a private chat to the users table, the
+// audience of /broadcast.
+func (m *command) recordUser(u *ext.Update) {
+	user := u.GetUserChat()
+	if user == nil {
+		return
+	}
+	err := database.TouchUser(&types.User{
+		UserID:    user.ID,
+		Username:  user.Username,
+		FirstName: user.FirstName,
+	})
+	if err != nil {
+		m.log.Error("Failed to record user", zap.Error(err), zap.Int64("userID", user.ID))
+	}
+}
+
+// broadcast queues a copy of the replied-to message for every active user.
+// Broadcasts are sent one at a time, and the reply is edited with the
+// progress.
+func (m *command) broadcast(ctx *ext.Context, u *ext.Update) error {
+	replyTo, ok := u.EffectiveMessage.ReplyTo.(*tg.MessageReplyHeader)
+	if !ok || replyTo.ReplyToMsgID == 0 {
+		reply(ctx, u, "broadcast_usage", nil, nil)
+		return dispatcher.EndGroups
+	}
+	chatId := u.EffectiveChat().GetID()
+	lang := userLanguage(u)
+	text, err := templates.Render(lang, "broadcast_queued", nil)
+	if err != nil {
+		m.log.Error("Failed to render message", zap.Error(err))
+		return dispatcher.EndGroups
+	}
+	status, err := ctx.Reply(u, text, nil)
+	if err != nil {
+		m.log.Error("Failed to send broadcast status", zap.Error(err))
+		return dispatcher.EndGroups
+	}
+
+	broadcastOnce.Do(func() {
+		go m.broadcastWorker(broadcastCtx, ctx.Raw, ctx.PeerStorage)
+	})
+	job := &broadcastJob{
+		chat:      ctx.PeerStorage.GetInputPeerById(chatId),
+		messageID: replyTo.ReplyToMsgID,
+		statusID:  status.ID,
+		lang:      lang,
+	}
+	select {
+	case broadcastQueue <- job:
+	default:
+		m.log.Warn("Broadcast queue is full")
+		m.broadcastStatus(ctx, ctx.Raw, job, "broadcast_busy", nil)
+	}
+	return dispatcher.EndGroups
+}
+
+// broadcastWorker sends the queued broadcasts, at most BROADCAST_RATE
+// messages per second, until ctx is cancelled.
+func (m *command) broadcastWorker(ctx context.Context, client *tg.Client, peerStorage *storage.PeerStorage) {
+	defer close(broadcastStopped)
+	log := m.log.Named("broadcast")
+	limiter := rate.NewLimiter(rate.Limit(max(config.ValueOf.BroadcastRate, 1)), 1)
+	for {
+		select {
+		case <-ctx.Done():
+			m.stopQueuedBroadcasts(client)
+			return
+		case job := <-broadcastQueue:
+			m.sendBroadcastJob(ctx, log, limiter, client, peerStorage, job)
+		}
+	}
+}
+
+// sendBroadcastJob copies the message of job to every active user, editing
+// its status message with the progress. If ctx is cancelled halfway, the
+// status shows how far it got.
+func (m *command) sendBroadcastJob(ctx context.Context, log *zap.Logger, limiter *rate.Limiter, client *tg.Client, peerStorage *storage.PeerStorage, job *broadcastJob) {
+	userIDs, err := database.GetActiveUserIDs()
+	if err != nil {
+		log.Error("Failed to get users", zap.Error(err))
+		m.broadcastStatus(ctx, client, job, "admin_failed", nil)
+		return
+	}
+	report := broadcastReport{Total: len(userIDs)}
+	log.Info("Broadcast started", zap.Int("users", report.Total))
+	m.broadcastStatus(ctx, client, job, "broadcast_progress", report)
+	lastStatus := time.Now()
+	for _, userID := range userIDs {
+		if limiter.Wait(ctx) != nil {
+			break
+		}
+		err := sendBroadcast(ctx, client, peerStorage, job, userID)
+		if err != nil && ctx.Err() != nil {
+			// not sent, the broadcast was stopped
+			break
+		}
+		report.Done++
+		switch {
+		case err == nil:
+			report.Delivered++
+		case isUnreachableUser(err):
+			report.Failed++
+			report.Blocked++
+			if err := database.MarkUserInactive(userID); err != nil {
+				log.Error("Failed to mark user inactive", zap.Error(err), zap.Int64("userID", userID))
+			}
+		default:
+			report.Failed++
+			log.Debug("Failed to deliver broadcast", zap.Error(err), zap.Int64("userID", userID))
+		}
+		if time.Since(lastStatus) >= broadcastProgressInterval {
+			m.broadcastStatus(ctx, client, job, "broadcast_progress", report)
+			lastStatus = time.Now()
+		}
+	}
+	if ctx.Err() != nil {
+		log.Info("Broadcast stopped",
+			zap.Int("done", report.Done),
+			zap.Int("total", report.Total))
+		stopCtx, cancel := context.WithTimeout(context.Background(), broadcastStopTimeout)
+		defer cancel()
+		m.broadcastStatus(stopCtx, client, job, "broadcast_stopped", report)
+		return
+	}
+	log.Info("Broadcast finished",
+		zap.Int("delivered", report.Delivered),
+		zap.Int("failed", report.Failed),
+		zap.Int("blocked", report.Blocked))
+	m.broadcastStatus(ctx, client, job, "broadcast_done", report)
+}
+
+// stopQueuedBroadcasts marks the broadcasts still in the queue as stopped
+// before any of their messages were sent.
+func (m *command) stopQueuedBroadcasts(client *tg.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), broadcastStopTimeout)
+	defer cancel()
+	for {
+		select {
+		case job := <-broadcastQueue:
+			m.broadcastStatus(ctx, client, job, "broadcast_stopped", broadcastReport{})
+		default:
+			return
+		}
+	}
+}
+
+// sendBroadcast copies the message of a job to a user, waiting out any
+// flood wait unless ctx is cancelled first.
+func sendBroadcast(ctx context.Context, client *tg.Client, peerStorage *storage.PeerStorage, job *broadcastJob, userID int64) error {
+	peer := peerStorage.GetInputPeerById(userID)
+	if _, ok := peer.(*tg.InputPeerEmpty); ok {
+		peer = &tg.InputPeerUser{UserID: userID}
+	}
+	for {
+		_, err := client.MessagesForwardMessages(ctx, &tg.MessagesForwardMessagesRequest{
+			RandomID:   []int64{rand.Int63()},
+			FromPeer:   job.chat,
+			ID:         []int{job.messageID},
+			ToPeer:     peer,
+			DropAuthor: true,
+		})
+		if wait, ok := tgerr.AsFloodWait(err); ok {
+			timer := time.NewTimer(wait + time.Second)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+			continue
+		}
+		return err
+	}
+}
+
+// isUnreachableUser reports whether a broadcast failed because the user
+// blocked the bot or deleted their account, so they can be left out of the
+// next ones. PEER_ID_INVALID is not one of these: it's also returned for
+// users whose access hash was lost with the session.
+func isUnreachableUser(err error) bool {
+	return tg.IsUserIsBlocked(err) || tg.IsInputUserDeactivated(err)
+}
+
+// broadcastStatus replaces the status message of a job with a template.
+func (m *command) broadcastStatus(ctx context.Context, client *tg.Client, job *broadcastJob, name string, data interface{}) {
+	text, entities, err := templates.RenderText(job.lang, name, data)
+	if err != nil {
+		m.log.Error("Failed to render message", zap.Error(err))
+		return
+	}
+	_, err = client.MessagesEditMessage(ctx, &tg.MessagesEditMessageRequest{
+		Peer:     job.chat,
+		ID:       job.statusID,
+		Message:  text,
+		Entities: entities,
+	})
+	if err != nil && !tg.IsMessageNotModified(err) {
+		m.log.Debug("Failed to update broadcast status", zap.Error(err))
+	}
+}
@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gotd/td/tgerr"
+)
+
+func TestIsUnreachableUser(t *testing.T) {
+	for err, want := range map[error]bool{
+		tgerr.New(403, "USER_IS_BLOCKED"):                            true,
+		tgerr.New(400, "INPUT_USER_DEACTIVATED"):                     true,
+		fmt.Errorf("forward: %w", tgerr.New(403, "USER_IS_BLOCKED")): true,
+		// a lost access hash, the user may still be reachable
+		tgerr.New(400, "PEER_ID_INVALID"):      false,
+		tgerr.New(400, "CHAT_WRITE_FORBIDDEN"): false,
+		context.Canceled:                       false,
+		errors.New("timeout"):                  false,
+	} {
+		if got := isUnreachableUser(err); got != want {
+			t.Errorf("isUnreachableUser(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/templates"
+	"EverythingSuckz/fsb/internal/utils"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/telegram/message/markup"
+	"go.uber.org/zap"
+)
+
+func (m *command) LoadBundle(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("bundle")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("bundle", m.bundle))
+	dispatcher.AddHandler(handlers.NewCommand("done", m.done))
+}
+
+// bundle starts collecting the next files the user sends into a playlist.
+func (m *command) bundle(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	title := strings.TrimSpace(strings.Join(u.Args()[1:], " "))
+	if title == "" {
+		title = "Bundle"
+	}
+	if _, err := database.OpenBundle(chatId, title); err != nil {
+		m.log.Error("Failed to open bundle", zap.Error(err))
+		reply(ctx, u, "bundle_failed", nil, nil)
+		return dispatcher.EndGroups
+	}
+	reply(ctx, u, "bundle_started", map[string]interface{}{"Title": title}, nil)
+	return dispatcher.EndGroups
+}
+
+// done closes the open bundle and replies with its playlist links.
+func (m *command) done(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	peerChatId := ctx.PeerStorage.GetPeerById(chatId)
+	if peerChatId.Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	playlist, err := database.CloseBundle(chatId)
+	if err != nil {
+		reply(ctx, u, "bundle_none", nil, nil)
+		return dispatcher.EndGroups
+	}
+	files, err := database.GetPlaylistFiles(playlist.ID)
+	if err != nil || len(files) == 0 {
+		reply(ctx, u, "bundle_empty", nil, nil)
+		return dispatcher.EndGroups
+	}
+	token := utils.NewViewerToken(chatId, utils.PlaylistScope(playlist.ID))
+	m3u8 := utils.WithViewerToken(utils.GetPlaylistLink(playlist.ID, "m3u8"), token)
+	reply(ctx, u, "bundle_done", map[string]interface{}{
+		"Title":   playlist.Title,
+		"Count":   len(files),
+		"M3U8URL": m3u8,
+		"XSPFURL": utils.WithViewerToken(utils.GetPlaylistLink(playlist.ID, "xspf"), token),
+	}, &ext.ReplyOpts{
+		NoWebpage: true,
+		Markup:    markup.InlineRow(markup.URL(templates.Plain(userLanguage(u), "button_playlist", nil), m3u8)),
+	})
+	return dispatcher.EndGroups
+}
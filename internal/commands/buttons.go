@@ -0,0 +1,203 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/templates"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"strconv"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/dispatcher/handlers/filters"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/functions"
+	"github.com/gotd/td/telegram/message/markup"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+const (
+	revokeCallbackPrefix = "rv:"
+	deleteCallbackPrefix = "rm:"
+	// refreshCallbackPrefix issues new viewer tokens, see VIEWER_TOKENS.
+	refreshCallbackPrefix = "vt:"
+)
+
+func (m *command) LoadButtons(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("buttons")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(revokeCallbackPrefix), m.revokeLink))
+	dispatcher.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(deleteCallbackPrefix), m.deleteLink))
+	dispatcher.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(refreshCallbackPrefix), m.refreshLink))
+}
+
+// linkButtons builds the inline keyboard of a link reply following the
+// LINK_BUTTONS layout: rows separated by ";" and buttons by ",". The stream
+// button is left out if the owner turned off the watch link in /settings.
+// With VIEWER_TOKENS the links carry tokens issued to viewerID and a refresh
+// button is added at the end.
+func linkButtons(lang string, viewerID int64, file *types.UserFile, settings *types.UserSettings) tg.ReplyMarkupClass {
+	token := utils.NewViewerToken(viewerID, utils.FileScope(file.MessageID))
+	var rows []tg.KeyboardButtonRow
+	for _, row := range strings.Split(config.ValueOf.LinkButtons, ";") {
+		var buttons []tg.KeyboardButtonClass
+		for _, name := range strings.Split(row, ",") {
+			name = strings.TrimSpace(name)
+			if name == "stream" && settings.HideWatchLink {
+				continue
+			}
+			if button := linkButton(lang, name, viewerID, file, token); button != nil {
+				buttons = append(buttons, button)
+			}
+		}
+		if len(buttons) != 0 {
+			rows = append(rows, markup.Row(buttons...))
+		}
+	}
+	if token != "" {
+		rows = append(rows, refreshButtonRow(lang, file.MessageID))
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return markup.InlineKeyboard(rows...)
+}
+
+// linkButton builds one of the LINK_BUTTONS. token opens the file; the
+// playlist gets its own, issued to viewerID.
+func linkButton(lang, name string, viewerID int64, file *types.UserFile, token string) tg.KeyboardButtonClass {
+	data := strconv.Itoa(file.MessageID)
+	switch name {
+	case "stream":
+		return markup.URL(templates.Plain(lang, "button_stream", nil), utils.WithViewerToken(utils.GetWatchLink(file.MessageID, file.Hash), token))
+	case "download":
+		return markup.URL(templates.Plain(lang, "button_download", nil), utils.WithViewerToken(utils.GetDownloadLink(file.MessageID, file.Hash), token))
+	case "share":
+		return markup.SwitchInline(templates.Plain(lang, "button_share", nil), file.FileName, false)
+	case "revoke":
+		return markup.Callback(templates.Plain(lang, "button_revoke", nil), []byte(revokeCallbackPrefix+data))
+	case "delete":
+		return markup.Callback(templates.Plain(lang, "button_delete", nil), []byte(deleteCallbackPrefix+data))
+	case "playlist":
+		if file.PlaylistID == "" || file.GroupedID == 0 {
+			return nil
+		}
+		playlistToken := utils.NewViewerToken(viewerID, utils.PlaylistScope(file.PlaylistID))
+		return markup.URL(templates.Plain(lang, "button_playlist", nil), utils.WithViewerToken(utils.GetPlaylistLink(file.PlaylistID, "m3u8"), playlistToken))
+	}
+	return nil
+}
+
+// revokeLink makes the links of a file stop working, keeping the file in the log channel.
+func (m *command) revokeLink(ctx *ext.Context, u *ext.Update) error {
+	file, ok := m.ownedCallbackFile(ctx, u, revokeCallbackPrefix)
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	if err := database.RevokeUserFile(file.MessageID); err != nil {
+		m.log.Error("Failed to revoke link", zap.Error(err))
+		answerCallback(ctx, u, "revoke_failed", true)
+		return dispatcher.EndGroups
+	}
+	chatId := functions.GetChatIdFromPeer(u.CallbackQuery.Peer)
+	text, entities, err := templates.RenderText(userLanguage(u), "link_revoked", file)
+	if err != nil {
+		m.log.Error("Failed to render message", zap.Error(err))
+	} else {
+		_, _ = ctx.EditMessage(chatId, &tg.MessagesEditMessageRequest{
+			ID:       u.CallbackQuery.MsgID,
+			Message:  text,
+			Entities: entities,
+		})
+	}
+	answerCallback(ctx, u, "callback_revoked", false)
+	return dispatcher.EndGroups
+}
+
+// deleteLink revokes the links of a file and removes it from the log channel.
+func (m *command) deleteLink(ctx *ext.Context, u *ext.Update) error {
+	file, ok := m.ownedCallbackFile(ctx, u, deleteCallbackPrefix)
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	if err := database.RevokeUserFile(file.MessageID); err != nil {
+		m.log.Error("Failed to revoke link", zap.Error(err))
+		answerCallback(ctx, u, "delete_failed", true)
+		return dispatcher.EndGroups
+	}
+	if err := deleteFromLogChannel(ctx, file.MessageID); err != nil {
+		m.log.Error("Failed to delete file from log channel", zap.Error(err), zap.Int("messageID", file.MessageID))
+	}
+	chatId := functions.GetChatIdFromPeer(u.CallbackQuery.Peer)
+	_ = ctx.DeleteMessages(chatId, []int{u.CallbackQuery.MsgID})
+	answerCallback(ctx, u, "callback_deleted", false)
+	return dispatcher.EndGroups
+}
+
+// deleteFromLogChannel deletes the message of a file from LOG_CHANNEL.
+func deleteFromLogChannel(ctx *ext.Context, messageID int) error {
+	channel, err := utils.GetLogChannelPeer(ctx, ctx.Raw, ctx.PeerStorage)
+	if err != nil {
+		return err
+	}
+	_, err = ctx.Raw.ChannelsDeleteMessages(ctx, &tg.ChannelsDeleteMessagesRequest{
+		Channel: channel,
+		ID:      []int{messageID},
+	})
+	return err
+}
+
+// ownedCallbackFile returns the file referenced by the callback data, making
+// sure the user who pressed the button owns it. The admins of the group or
+// channel a file was posted in can act on it too, as can the bot admins on
+// any file.
+func (m *command) ownedCallbackFile(ctx *ext.Context, u *ext.Update, prefix string) (*types.UserFile, bool) {
+	messageID, err := strconv.Atoi(strings.TrimPrefix(string(u.CallbackQuery.Data), prefix))
+	if err != nil {
+		answerCallback(ctx, u, "callback_invalid", true)
+		return nil, false
+	}
+	file, err := database.GetUserFile(messageID)
+	if err != nil {
+		answerCallback(ctx, u, "callback_missing", true)
+		return nil, false
+	}
+	userID := u.CallbackQuery.UserID
+	if file.OwnerID == userID || isAdmin(userID) {
+		return file, true
+	}
+	chatID := fileChat(file)
+	chatAdmin, err := isUserChatAdmin(ctx, chatID, userID)
+	if err != nil {
+		m.log.Error("Failed to check chat admin", zap.Error(err), zap.Int64("chatID", chatID))
+	}
+	if !chatAdmin {
+		answerCallback(ctx, u, "callback_not_owner", true)
+		return nil, false
+	}
+	return file, true
+}
+
+// fileChat returns the group or channel a file was posted in, or 0 for
+// files sent in private.
+func fileChat(file *types.UserFile) int64 {
+	if file.ChatID == 0 {
+		// channel posts registered before ChatID was recorded are owned by
+		// the channel, any other owner is not a chat
+		return file.OwnerID
+	}
+	return file.ChatID
+}
+
+// answerCallback answers the callback query with the named message template
+// in the language of the user.
+func answerCallback(ctx *ext.Context, u *ext.Update, name string, alert bool) {
+	_, _ = ctx.AnswerCallback(&tg.MessagesSetBotCallbackAnswerRequest{
+		QueryID: u.CallbackQuery.QueryID,
+		Message: templates.Plain(userLanguage(u), name, nil),
+		Alert:   alert,
+	})
+}
@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/cache"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/templates"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/dispatcher/handlers/filters"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/telegram/message/markup"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// channelCaptionData is what the "channel_caption" message template
+// (or CHANNEL_CAPTION_TEMPLATE) is executed with.
+type channelCaptionData struct {
+	Caption     string
+	FileName    string
+	FileSize    string
+	StreamURL   string
+	DownloadURL string
+}
+
+func (m *command) LoadChannel(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("channel")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.Message{
+		Callback:      m.channelPost,
+		Filters:       filters.Message.Media,
+		UpdateFilters: isNewChannelPost,
+		Outgoing:      true,
+	})
+}
+
+func isNewChannelPost(u *ext.Update) bool {
+	_, ok := u.UpdateClass.(*tg.UpdateNewChannelMessage)
+	return ok && filters.Channel(u)
+}
+
+// channelPost adds the links to new media posts of opted-in channels, either
+// in the caption or as inline URL buttons. Channels in reply mode are left to
+// sendLink.
+func (m *command) channelPost(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	settings, err := database.GetChatSettings(chatId)
+	if err != nil || !settings.Enabled || settings.Mode == types.ChatModeReply {
+		return nil
+	}
+	if !settings.AllowsMedia(utils.MediaType(u.EffectiveMessage.Media)) {
+		return dispatcher.EndGroups
+	}
+
+	// the quota of the channel applies, without a reply that would be
+	// posted to its subscribers
+	media, err := utils.FileFromMedia(u.EffectiveMessage.Media)
+	if err != nil {
+		m.log.Error("Failed to read file of channel post", zap.Error(err), zap.Int64("chatID", chatId))
+		return dispatcher.EndGroups
+	}
+	day := cache.StatsToday()
+	if name, _ := reserveQuota(chatId, day, media.FileSize); name != "" {
+		m.log.Info("Channel post over quota", zap.Int64("chatID", chatId), zap.String("quota", name))
+		return dispatcher.EndGroups
+	}
+
+	msgID, file, err := forwardToLogChannel(ctx, chatId, u.EffectiveMessage.ID)
+	if err != nil {
+		m.log.Error("Failed to forward channel post to log channel", zap.Error(err), zap.Int64("chatID", chatId))
+		m.releaseQuota(chatId, day, media.FileSize)
+		return dispatcher.EndGroups
+	}
+	fullHash := utils.PackFile(file.FileName, file.FileSize, file.MimeType, file.ID)
+	m.registerFile(chatId, chatId, msgID, fullHash, file, u.EffectiveMessage.GroupedID)
+	if stats := cache.GetStatsCache(); stats != nil {
+		_ = stats.RecordFileProcessed(file.FileSize)
+	}
+
+	// with VIEWER_TOKENS the links of the post expire, and its members get
+	// their own from the refresh button
+	token := utils.NewViewerToken(chatId, utils.FileScope(msgID))
+	streamURL := utils.WithViewerToken(utils.GetStreamLink(msgID, fullHash), token)
+	downloadURL := utils.WithViewerToken(utils.GetDownloadLink(msgID, fullHash), token)
+	request := &tg.MessagesEditMessageRequest{ID: u.EffectiveMessage.ID}
+	linkRow := markup.Row(
+		markup.URL(templates.Plain(templates.DefaultLocale, "button_stream", nil), streamURL),
+		markup.URL(templates.Plain(templates.DefaultLocale, "button_download", nil), downloadURL),
+	)
+	var rows []tg.KeyboardButtonRow
+	if settings.Mode == types.ChatModeButtons {
+		rows = append(rows, linkRow)
+	} else {
+		text, entities, err := templates.RenderText(templates.DefaultLocale, "channel_caption", channelCaptionData{
+			Caption:     u.EffectiveMessage.Text,
+			FileName:    file.FileName,
+			FileSize:    formatFileSize(file.FileSize),
+			StreamURL:   streamURL,
+			DownloadURL: downloadURL,
+		})
+		if err != nil {
+			m.log.Error("Failed to render channel caption", zap.Error(err))
+			return dispatcher.EndGroups
+		}
+		if fitsCaption(text) {
+			request.Message = text
+			request.Entities = entities
+			// the original entities stay valid as long as the caption is kept as a prefix
+			if strings.HasPrefix(text, u.EffectiveMessage.Text) {
+				request.Entities = append(u.EffectiveMessage.Entities, entities...)
+			}
+		} else {
+			// Telegram would refuse the edit, so the caption is left as it
+			// was and the links go in buttons instead
+			m.log.Info("Channel caption too long, adding link buttons", zap.Int64("chatID", chatId))
+			rows = append(rows, linkRow)
+		}
+	}
+	if token != "" {
+		rows = append(rows, refreshURLButtonRow(templates.DefaultLocale, ctx.Self.Username, msgID))
+	}
+	if len(rows) != 0 {
+		request.ReplyMarkup = markup.InlineKeyboard(rows...)
+	}
+	if _, err := ctx.EditMessage(chatId, request); err != nil {
+		m.log.Error("Failed to edit channel post", zap.Error(err), zap.Int64("chatID", chatId))
+	}
+	return dispatcher.EndGroups
+}
+
+// maxCaptionLength is the longest caption Telegram accepts, in UTF-16 code
+// units as it measures text.
+const maxCaptionLength = 1024
+
+// fitsCaption reports whether text is short enough for a media caption.
+func fitsCaption(text string) bool {
+	return len(utf16.Encode([]rune(text))) <= maxCaptionLength
+}
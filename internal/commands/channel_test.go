@@ -0,0 +1,25 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFitsCaption(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		text string
+		want bool
+	}{
+		{"empty", "", true},
+		{"at the limit", strings.Repeat("a", maxCaptionLength), true},
+		{"over the limit", strings.Repeat("a", maxCaptionLength+1), false},
+		// Telegram counts UTF-16 code units, and the emoji takes two
+		{"emoji over the limit", strings.Repeat("a", maxCaptionLength-1) + "🚀", false},
+		{"accents at the limit", strings.Repeat("é", maxCaptionLength), true},
+	} {
+		if got := fitsCaption(tt.text); got != tt.want {
+			t.Errorf("%s: fitsCaption = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
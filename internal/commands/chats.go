@@ -0,0 +1,287 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"errors"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var chatMediaTypes = []string{"video", "audio", "document", "photo"}
+
+func (m *command) LoadChats(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("chats")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("enable", m.enableChat))
+	dispatcher.AddHandler(handlers.NewCommand("disable", m.disableChat))
+	dispatcher.AddHandler(handlers.NewCommand("chatsettings", m.chatSettings))
+}
+
+// enableChat opts a group or channel in, after which media posted there gets links.
+func (m *command) enableChat(ctx *ext.Context, u *ext.Update) error {
+	chatId, ok := m.checkChatAdmin(ctx, u)
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	settings, err := getOrDefaultChatSettings(chatId)
+	if err != nil {
+		m.log.Error("Failed to get chat settings", zap.Error(err))
+		return dispatcher.EndGroups
+	}
+	settings.Enabled = true
+	settings.EnabledBy = senderID(u)
+	if err := database.SaveChatSettings(settings); err != nil {
+		m.log.Error("Failed to save chat settings", zap.Error(err))
+		reply(ctx, u, "chat_enable_failed", nil, nil)
+		return dispatcher.EndGroups
+	}
+	reply(ctx, u, "chat_enabled", settings, nil)
+	return dispatcher.EndGroups
+}
+
+// disableChat opts a group or channel out again.
+func (m *command) disableChat(ctx *ext.Context, u *ext.Update) error {
+	chatId, ok := m.checkChatAdmin(ctx, u)
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	settings, err := database.GetChatSettings(chatId)
+	if err != nil || !settings.Enabled {
+		reply(ctx, u, "chat_not_enabled", nil, nil)
+		return dispatcher.EndGroups
+	}
+	settings.Enabled = false
+	if err := database.SaveChatSettings(settings); err != nil {
+		m.log.Error("Failed to save chat settings", zap.Error(err))
+		return dispatcher.EndGroups
+	}
+	reply(ctx, u, "chat_disabled", nil, nil)
+	return dispatcher.EndGroups
+}
+
+// chatSettings shows or changes the settings of a chat:
+//
+//	/chatsettings mode reply|caption|buttons
+//	/chatsettings media video,audio,document,photo
+func (m *command) chatSettings(ctx *ext.Context, u *ext.Update) error {
+	chatId, ok := m.checkChatAdmin(ctx, u)
+	if !ok {
+		return dispatcher.EndGroups
+	}
+	settings, err := getOrDefaultChatSettings(chatId)
+	if err != nil {
+		m.log.Error("Failed to get chat settings", zap.Error(err))
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	if len(args) < 3 {
+		reply(ctx, u, "chat_settings_usage", map[string]interface{}{
+			"Settings":   settings,
+			"MediaTypes": strings.Join(chatMediaTypes, ","),
+		}, nil)
+		return dispatcher.EndGroups
+	}
+	switch strings.ToLower(args[1]) {
+	case "mode":
+		mode := strings.ToLower(args[2])
+		if mode != types.ChatModeReply && mode != types.ChatModeCaption && mode != types.ChatModeButtons {
+			reply(ctx, u, "chat_invalid_mode", nil, nil)
+			return dispatcher.EndGroups
+		}
+		if mode != types.ChatModeReply && !isBroadcastChannel(u, chatId) {
+			reply(ctx, u, "chat_channel_only_mode", nil, nil)
+			return dispatcher.EndGroups
+		}
+		settings.Mode = mode
+	case "media":
+		var mediaTypes []string
+		for _, t := range strings.Split(strings.ToLower(strings.Join(args[2:], "")), ",") {
+			if !utils.Contains(chatMediaTypes, t) {
+				reply(ctx, u, "chat_unknown_media", map[string]interface{}{
+					"Type":       t,
+					"MediaTypes": strings.Join(chatMediaTypes, ","),
+				}, nil)
+				return dispatcher.EndGroups
+			}
+			mediaTypes = append(mediaTypes, t)
+		}
+		settings.MediaTypes = strings.Join(mediaTypes, ",")
+	default:
+		reply(ctx, u, "chat_unknown_setting", nil, nil)
+		return dispatcher.EndGroups
+	}
+	if err := database.SaveChatSettings(settings); err != nil {
+		m.log.Error("Failed to save chat settings", zap.Error(err))
+		return dispatcher.EndGroups
+	}
+	reply(ctx, u, "chat_updated", settings, nil)
+	return dispatcher.EndGroups
+}
+
+// checkChatAdmin makes sure the command was sent in a group or channel by
+// one of its admins, and returns the chat ID.
+func (m *command) checkChatAdmin(ctx *ext.Context, u *ext.Update) (int64, bool) {
+	chatId := u.EffectiveChat().GetID()
+	peer := ctx.PeerStorage.GetPeerById(chatId)
+	if peer.Type == int(storage.TypeUser) {
+		reply(ctx, u, "chat_only", nil, nil)
+		return chatId, false
+	}
+	isAdmin, err := isChatAdmin(ctx, u, chatId)
+	if err != nil {
+		m.log.Error("Failed to check chat admin", zap.Error(err), zap.Int64("chatID", chatId))
+	}
+	if !isAdmin {
+		reply(ctx, u, "chat_admin_only", nil, nil)
+		return chatId, false
+	}
+	return chatId, true
+}
+
+// isChatAdmin reports whether the sender of the update is an admin of the chat.
+func isChatAdmin(ctx *ext.Context, u *ext.Update, chatId int64) (bool, error) {
+	from, ok := u.EffectiveMessage.FromID.(*tg.PeerUser)
+	if !ok {
+		// channel posts and anonymous admins are sent on behalf of the chat itself
+		if channel, ok := u.EffectiveMessage.FromID.(*tg.PeerChannel); ok {
+			return channel.ChannelID == chatId, nil
+		}
+		return u.EffectiveMessage.FromID == nil && isBroadcastChannel(u, chatId), nil
+	}
+	return isUserChatAdmin(ctx, chatId, from.UserID)
+}
+
+// isUserChatAdmin reports whether userID is an admin of the group or
+// channel chatId. It's false for any other kind of chat.
+func isUserChatAdmin(ctx *ext.Context, chatId, userID int64) (bool, error) {
+	switch peer := ctx.PeerStorage.GetInputPeerById(chatId).(type) {
+	case *tg.InputPeerChannel:
+		res, err := ctx.Raw.ChannelsGetParticipant(ctx, &tg.ChannelsGetParticipantRequest{
+			Channel:     &tg.InputChannel{ChannelID: peer.ChannelID, AccessHash: peer.AccessHash},
+			Participant: ctx.PeerStorage.GetInputPeerById(userID),
+		})
+		if err != nil {
+			return false, err
+		}
+		switch res.Participant.(type) {
+		case *tg.ChannelParticipantCreator, *tg.ChannelParticipantAdmin:
+			return true, nil
+		}
+		return false, nil
+	case *tg.InputPeerChat:
+		return isBasicGroupAdmin(ctx, peer.ChatID, userID)
+	}
+	return false, nil
+}
+
+// isUserChatMember reports whether userID is in the group or channel chatId
+// and can read it. It's false for any other kind of chat.
+func isUserChatMember(ctx *ext.Context, chatId, userID int64) (bool, error) {
+	switch peer := ctx.PeerStorage.GetInputPeerById(chatId).(type) {
+	case *tg.InputPeerChannel:
+		res, err := ctx.Raw.ChannelsGetParticipant(ctx, &tg.ChannelsGetParticipantRequest{
+			Channel:     &tg.InputChannel{ChannelID: peer.ChannelID, AccessHash: peer.AccessHash},
+			Participant: &tg.InputPeerUser{UserID: userID},
+		})
+		if err != nil {
+			if tgerr.Is(err, "USER_NOT_PARTICIPANT", "PARTICIPANT_NOT_EXIST") {
+				return false, nil
+			}
+			return false, err
+		}
+		switch p := res.Participant.(type) {
+		case *tg.ChannelParticipantLeft:
+			return false, nil
+		case *tg.ChannelParticipantBanned:
+			return !p.Left && !p.BannedRights.ViewMessages, nil
+		}
+		return true, nil
+	case *tg.InputPeerChat:
+		full, err := ctx.Raw.MessagesGetFullChat(ctx, peer.ChatID)
+		if err != nil {
+			return false, err
+		}
+		chatFull, ok := full.FullChat.(*tg.ChatFull)
+		if !ok {
+			return false, errors.New("unexpected full chat type")
+		}
+		participants, ok := chatFull.Participants.(*tg.ChatParticipants)
+		if !ok {
+			return false, nil
+		}
+		for _, participant := range participants.Participants {
+			if participant.GetUserID() == userID {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// isBasicGroupAdmin reports whether userID is the creator or an admin of a
+// basic group, which has no participant lookup like channels do.
+func isBasicGroupAdmin(ctx *ext.Context, chatId, userID int64) (bool, error) {
+	full, err := ctx.Raw.MessagesGetFullChat(ctx, chatId)
+	if err != nil {
+		return false, err
+	}
+	chatFull, ok := full.FullChat.(*tg.ChatFull)
+	if !ok {
+		return false, errors.New("unexpected full chat type")
+	}
+	participants, ok := chatFull.Participants.(*tg.ChatParticipants)
+	if !ok {
+		return false, nil
+	}
+	for _, participant := range participants.Participants {
+		switch p := participant.(type) {
+		case *tg.ChatParticipantCreator:
+			if p.UserID == userID {
+				return true, nil
+			}
+		case *tg.ChatParticipantAdmin:
+			if p.UserID == userID {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// isBroadcastChannel reports whether the chat is a channel rather than a group.
+func isBroadcastChannel(u *ext.Update, chatId int64) bool {
+	if channel, ok := u.Entities.Channels[chatId]; ok {
+		return !channel.Megagroup
+	}
+	return false
+}
+
+// senderID returns the user who sent the message, or the chat for channel posts.
+func senderID(u *ext.Update) int64 {
+	if from, ok := u.EffectiveMessage.FromID.(*tg.PeerUser); ok {
+		return from.UserID
+	}
+	return u.EffectiveChat().GetID()
+}
+
+func getOrDefaultChatSettings(chatId int64) (*types.ChatSettings, error) {
+	settings, err := database.GetChatSettings(chatId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &types.ChatSettings{
+			ChatID:     chatId,
+			Mode:       types.ChatModeReply,
+			MediaTypes: types.DefaultChatMediaTypes,
+		}, nil
+	}
+	return settings, err
+}
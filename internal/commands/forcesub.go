@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/templates"
+	"EverythingSuckz/fsb/internal/utils"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/dispatcher/handlers/filters"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/functions"
+	"github.com/gotd/td/telegram/message/markup"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+const forceSubRetryCallback = "fsub:retry"
+
+func (m *command) LoadForceSub(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("forcesub")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Equal(forceSubRetryCallback), m.forceSubRetry))
+}
+
+// checkForceSub replies with the join buttons if the user is missing any of
+// the force-sub channels, returning false.
+func (m *command) checkForceSub(ctx *ext.Context, u *ext.Update, userID int64) bool {
+	if len(utils.ForceSubChannels()) == 0 {
+		return true
+	}
+	missing, err := utils.MissingSubscriptions(ctx, ctx.Raw, ctx.PeerStorage, userID)
+	if err != nil {
+		m.log.Error("Failed to check force-sub channels", zap.Error(err), zap.Int64("userID", userID))
+		reply(ctx, u, "force_sub_failed", nil, nil)
+		return false
+	}
+	if len(missing) == 0 {
+		return true
+	}
+	reply(ctx, u, "force_sub", map[string]interface{}{"Count": len(missing)}, &ext.ReplyOpts{
+		Markup: forceSubButtons(userLanguage(u), missing),
+	})
+	return false
+}
+
+// forceSubRetry checks the membership again, skipping the cache, after the
+// user taps "I've joined".
+func (m *command) forceSubRetry(ctx *ext.Context, u *ext.Update) error {
+	userID := u.CallbackQuery.UserID
+	utils.ForgetSubscriptions(userID)
+	missing, err := utils.MissingSubscriptions(ctx, ctx.Raw, ctx.PeerStorage, userID)
+	if err != nil {
+		m.log.Error("Failed to check force-sub channels", zap.Error(err), zap.Int64("userID", userID))
+		answerCallback(ctx, u, "force_sub_failed", true)
+		return dispatcher.EndGroups
+	}
+	if len(missing) != 0 {
+		answerCallback(ctx, u, "callback_force_sub_missing", true)
+		return dispatcher.EndGroups
+	}
+	text, entities, err := templates.RenderText(userLanguage(u), "force_sub_done", nil)
+	if err != nil {
+		m.log.Error("Failed to render message", zap.Error(err))
+	} else {
+		chatId := functions.GetChatIdFromPeer(u.CallbackQuery.Peer)
+		_, _ = ctx.EditMessage(chatId, &tg.MessagesEditMessageRequest{
+			ID:       u.CallbackQuery.MsgID,
+			Message:  text,
+			Entities: entities,
+		})
+	}
+	answerCallback(ctx, u, "callback_force_sub_done", false)
+	return dispatcher.EndGroups
+}
+
+// forceSubButtons has one join button per missing channel followed by the
+// retry button.
+func forceSubButtons(lang string, channels []utils.ForceSubChannel) tg.ReplyMarkupClass {
+	var rows []tg.KeyboardButtonRow
+	for i, channel := range channels {
+		label := templates.Plain(lang, "button_join", map[string]interface{}{
+			"Username": channel.Username,
+			"Number":   i + 1,
+		})
+		rows = append(rows, markup.Row(markup.URL(label, channel.JoinURL)))
+	}
+	rows = append(rows, markup.Row(
+		markup.Callback(templates.Plain(lang, "button_force_sub_retry", nil), []byte(forceSubRetryCallback)),
+	))
+	return markup.InlineKeyboard(rows...)
+}
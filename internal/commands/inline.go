@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/templates"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"strconv"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/dispatcher/handlers/filters"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/telegram/message/markup"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+const inlineResultsLimit = 20
+
+func (m *command) LoadInline(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("inline")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewInlineQuery(filters.InlineQuery.All, m.inlineQuery))
+}
+
+// inlineQuery answers "@bot <name>" with the user's previously processed files.
+func (m *command) inlineQuery(ctx *ext.Context, u *ext.Update) error {
+	query := u.InlineQuery
+	userID := query.UserID
+	lang := userLanguage(u)
+	offset, _ := strconv.Atoi(query.Offset)
+	files, err := database.SearchUserFiles(userID, strings.TrimSpace(query.Query), offset, inlineResultsLimit)
+	if err != nil {
+		m.log.Error("Failed to search user files", zap.Error(err))
+		return dispatcher.EndGroups
+	}
+
+	results := make([]tg.InputBotInlineResultClass, 0, len(files))
+	for i := range files {
+		result, err := inlineFileResult(lang, userID, ctx.Self.Username, &files[i])
+		if err != nil {
+			m.log.Error("Failed to render inline result", zap.Error(err))
+			return dispatcher.EndGroups
+		}
+		results = append(results, result)
+	}
+	var nextOffset string
+	if len(files) == inlineResultsLimit {
+		nextOffset = strconv.Itoa(offset + inlineResultsLimit)
+	}
+	_, err = ctx.SetInlineBotResult(&tg.MessagesSetInlineBotResultsRequest{
+		QueryID:    query.QueryID,
+		Results:    results,
+		CacheTime:  10,
+		Private:    true,
+		NextOffset: nextOffset,
+	})
+	if err != nil {
+		m.log.Error("Failed to answer inline query", zap.Error(err))
+	}
+	return dispatcher.EndGroups
+}
+
+// inlineFileResult builds the result of a file. With VIEWER_TOKENS its links
+// carry a token issued to viewerID, plus a button to get new ones from the
+// bot once they expire.
+func inlineFileResult(lang string, viewerID int64, botUsername string, file *types.UserFile) (tg.InputBotInlineResultClass, error) {
+	token := utils.NewViewerToken(viewerID, utils.FileScope(file.MessageID))
+	streamURL := utils.WithViewerToken(utils.GetStreamLink(file.MessageID, file.Hash), token)
+	size := formatFileSize(file.FileSize)
+	text, entities, err := templates.RenderText(lang, "inline_result", linkMessageData{
+		FileName:  file.FileName,
+		FileSize:  size,
+		StreamURL: streamURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	rows := []tg.KeyboardButtonRow{markup.Row(
+		markup.URL(templates.Plain(lang, "button_stream", nil), streamURL),
+		markup.URL(templates.Plain(lang, "button_download", nil), utils.WithViewerToken(utils.GetDownloadLink(file.MessageID, file.Hash), token)),
+	)}
+	if token != "" {
+		rows = append(rows, refreshURLButtonRow(lang, botUsername, file.MessageID))
+	}
+	result := &tg.InputBotInlineResult{
+		ID:          strconv.Itoa(file.MessageID),
+		Type:        "article",
+		Title:       file.FileName,
+		Description: strings.TrimSpace(size + " " + file.MimeType),
+		SendMessage: &tg.InputBotInlineMessageText{
+			NoWebpage:   true,
+			Message:     text,
+			Entities:    entities,
+			ReplyMarkup: markup.InlineKeyboard(rows...),
+		},
+	}
+	if file.HasThumb {
+		result.Thumb = tg.InputWebDocument{
+			URL:        utils.WithViewerToken(utils.GetThumbLink(file.MessageID, file.Hash), token),
+			MimeType:   "image/jpeg",
+			Attributes: []tg.DocumentAttributeClass{},
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/templates"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/dispatcher/handlers/filters"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/functions"
+	"github.com/gotd/td/telegram/message/markup"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+const (
+	languageCallbackPrefix = "lang:"
+	// languageAuto clears the /language choice so the Telegram language is used again.
+	languageAuto = "auto"
+)
+
+func (m *command) LoadLanguage(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("language")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("language", m.language))
+	dispatcher.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(languageCallbackPrefix), m.languageCallback))
+}
+
+// language shows the available languages, or sets one directly with
+// "/language es" ("/language auto" follows the Telegram app again).
+func (m *command) language(ctx *ext.Context, u *ext.Update) error {
+	args := u.Args()
+	if len(args) < 2 {
+		reply(ctx, u, "language_choose", nil, &ext.ReplyOpts{Markup: languageButtons(userLanguage(u))})
+		return dispatcher.EndGroups
+	}
+	choice := strings.ToLower(args[1])
+	if choice != languageAuto && !utils.Contains(templates.Locales(), choice) {
+		reply(ctx, u, "language_unknown", map[string]interface{}{
+			"Language":  choice,
+			"Available": strings.Join(append(templates.Locales(), languageAuto), ", "),
+		}, nil)
+		return dispatcher.EndGroups
+	}
+	if err := setUserLanguage(senderID(u), choice); err != nil {
+		m.log.Error("Failed to save language", zap.Error(err))
+		return dispatcher.EndGroups
+	}
+	reply(ctx, u, "language_set", nil, nil)
+	return dispatcher.EndGroups
+}
+
+func (m *command) languageCallback(ctx *ext.Context, u *ext.Update) error {
+	choice := strings.TrimPrefix(string(u.CallbackQuery.Data), languageCallbackPrefix)
+	if choice != languageAuto && !utils.Contains(templates.Locales(), choice) {
+		answerCallback(ctx, u, "callback_invalid", true)
+		return dispatcher.EndGroups
+	}
+	if err := setUserLanguage(u.CallbackQuery.UserID, choice); err != nil {
+		m.log.Error("Failed to save language", zap.Error(err))
+		return dispatcher.EndGroups
+	}
+	text, entities, err := templates.RenderText(userLanguage(u), "language_set", nil)
+	if err != nil {
+		m.log.Error("Failed to render message", zap.Error(err))
+	} else {
+		chatId := functions.GetChatIdFromPeer(u.CallbackQuery.Peer)
+		_, _ = ctx.EditMessage(chatId, &tg.MessagesEditMessageRequest{
+			ID:       u.CallbackQuery.MsgID,
+			Message:  text,
+			Entities: entities,
+		})
+	}
+	answerCallback(ctx, u, "language_set", false)
+	return dispatcher.EndGroups
+}
+
+func languageButtons(lang string) tg.ReplyMarkupClass {
+	var rows []tg.KeyboardButtonRow
+	for _, locale := range templates.Locales() {
+		rows = append(rows, markup.Row(
+			markup.Callback(templates.Plain(locale, "language_name", nil), []byte(languageCallbackPrefix+locale)),
+		))
+	}
+	rows = append(rows, markup.Row(
+		markup.Callback(templates.Plain(lang, "button_language_auto", nil), []byte(languageCallbackPrefix+languageAuto)),
+	))
+	return markup.InlineKeyboard(rows...)
+}
+
+func setUserLanguage(userID int64, choice string) error {
+	settings, err := database.GetUserSettings(userID)
+	if err != nil {
+		settings = &types.UserSettings{UserID: userID}
+	}
+	if choice == languageAuto {
+		choice = ""
+	}
+	settings.Language = choice
+	return database.SaveUserSettings(settings)
+}
+
+// userLanguage returns the locale of the user behind the update: the one
+// chosen with /language, or else the language of their Telegram app.
+func userLanguage(u *ext.Update) string {
+	userID := updateUserID(u)
+	if userID == 0 {
+		return templates.DefaultLocale
+	}
+	if settings, err := database.GetUserSettings(userID); err == nil && settings.Language != "" {
+		return templates.Locale(settings.Language)
+	}
+	if u.Entities != nil {
+		if user, ok := u.Entities.Users[userID]; ok {
+			return templates.Locale(user.LangCode)
+		}
+	}
+	return templates.DefaultLocale
+}
@@ -0,0 +1,22 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/templates"
+	"EverythingSuckz/fsb/internal/utils"
+
+	"github.com/celestix/gotgproto/ext"
+	"go.uber.org/zap"
+)
+
+// reply renders the named message template in the language of the user and
+// sends it as a reply to the update.
+func reply(ctx *ext.Context, u *ext.Update, name string, data interface{}, opts *ext.ReplyOpts) {
+	text, err := templates.Render(userLanguage(u), name, data)
+	if err != nil {
+		utils.Logger.Error("Failed to render message", zap.String("template", name), zap.Error(err))
+		return
+	}
+	if _, err := ctx.Reply(u, text, opts); err != nil {
+		utils.Logger.Debug("Failed to send message", zap.String("template", name), zap.Error(err))
+	}
+}
@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/utils"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+)
+
+// middlewareGroup runs before the handlers of every other group.
+const middlewareGroup = -1
+
+func (m *command) LoadMiddleware(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("middleware")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandlerToGroup(handlers.NewAnyUpdate(m.checkAccess), middlewareGroup)
+}
+
+// checkAccess enforces the ban and allow lists before any handler runs.
+func (m *command) checkAccess(ctx *ext.Context, u *ext.Update) error {
+	if name := accessRefusal(ctx, u); name != "" {
+		refuse(ctx, u, name)
+		return dispatcher.EndGroups
+	}
+	return nil
+}
+
+// accessRefusal returns the message template the update is refused with, or
+// "" if it may go on. Banned users are ignored everywhere. When there is an
+// allow list, other users can't use the bot directly, but media they post in
+// chats enabled by an allowed admin still gets links.
+func accessRefusal(ctx *ext.Context, u *ext.Update) string {
+	userID := updateUserID(u)
+	if userID == 0 || isAdmin(userID) {
+		return ""
+	}
+	if database.IsBanned(userID) {
+		return "banned"
+	}
+	if isAllowed(userID) || !isDirectUse(ctx, u) {
+		return ""
+	}
+	return "not_allowed"
+}
+
+// isAllowed reports whether the user is in ALLOWED_USERS or the allow list,
+// or whether there is no allow list at all.
+func isAllowed(userID int64) bool {
+	if len(config.ValueOf.AllowedUsers) == 0 && !database.HasAllowList() {
+		return true
+	}
+	return isAdmin(userID) || utils.Contains(config.ValueOf.AllowedUsers, userID) || database.IsAllowed(userID)
+}
+
+// isDirectUse reports whether the update is addressed to the bot itself:
+// private messages, commands, inline queries and button presses.
+func isDirectUse(ctx *ext.Context, u *ext.Update) bool {
+	if u.EffectiveMessage == nil || isPrivateChat(ctx, u) {
+		return true
+	}
+	// posts made on behalf of a chat come from its admins
+	if senderID(u) == u.EffectiveChat().GetID() {
+		return false
+	}
+	return strings.HasPrefix(u.EffectiveMessage.Text, "/")
+}
+
+func isPrivateChat(ctx *ext.Context, u *ext.Update) bool {
+	return ctx.PeerStorage.GetPeerById(u.EffectiveChat().GetID()).Type == int(storage.TypeUser)
+}
+
+// refuse tells the user why the update was dropped, where there is a way to.
+func refuse(ctx *ext.Context, u *ext.Update, name string) {
+	switch {
+	case u.CallbackQuery != nil:
+		answerCallback(ctx, u, name, true)
+	case u.InlineQuery != nil:
+		_, _ = ctx.SetInlineBotResult(&tg.MessagesSetInlineBotResultsRequest{
+			QueryID:   u.InlineQuery.QueryID,
+			Results:   []tg.InputBotInlineResultClass{},
+			CacheTime: 60,
+			Private:   true,
+		})
+	case u.EffectiveMessage != nil && isPrivateChat(ctx, u):
+		reply(ctx, u, name, nil, nil)
+	}
+}
+
+// updateUserID returns the user behind the update, or 0 if there is none.
+// Channel posts and anonymous admins are attributed to the chat itself.
+func updateUserID(u *ext.Update) int64 {
+	switch {
+	case u.CallbackQuery != nil:
+		return u.CallbackQuery.UserID
+	case u.InlineQuery != nil:
+		return u.InlineQuery.UserID
+	case u.EffectiveMessage != nil:
+		return senderID(u)
+	}
+	return 0
+}
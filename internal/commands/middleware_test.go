@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"path/filepath"
+	"testing"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	gotgtypes "github.com/celestix/gotgproto/types"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+const (
+	testAdmin   = 1
+	testAllowed = 2
+	testStrange = 3
+	testBanned  = 4
+	testGroup   = 100
+)
+
+// accessTestContext returns a context whose peer storage knows the test
+// users and group, and sets up the admins, ALLOWED_USERS and a database
+// with testBanned banned.
+func accessTestContext(t *testing.T, allowed []int64) *ext.Context {
+	t.Helper()
+	previous := *config.ValueOf
+	t.Cleanup(func() { *config.ValueOf = previous })
+	config.ValueOf.GithubDbPath = filepath.Join(t.TempDir(), "fsb.db")
+	config.ValueOf.AdminIDs = []int64{testAdmin}
+	config.ValueOf.AllowedUsers = allowed
+	if err := database.InitDatabase(zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.BanUser(&types.BannedUser{UserID: testBanned}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _, _ = database.UnbanUser(testBanned) })
+
+	peers := storage.NewPeerStorage(nil, true)
+	for _, id := range []int64{testAdmin, testAllowed, testStrange, testBanned} {
+		peers.AddPeer(id, 0, storage.TypeUser, "")
+	}
+	peers.AddPeer(testGroup, 0, storage.TypeChat, "")
+	return &ext.Context{PeerStorage: peers}
+}
+
+// messageUpdate is a message from a user, in private or in the test group.
+func messageUpdate(from int64, inGroup bool, text string) *ext.Update {
+	msg := &tg.Message{FromID: &tg.PeerUser{UserID: from}, Message: text}
+	entities := &tg.Entities{Users: map[int64]*tg.User{from: {ID: from}}}
+	if inGroup {
+		msg.PeerID = &tg.PeerChat{ChatID: testGroup}
+		entities.Chats = map[int64]*tg.Chat{testGroup: {ID: testGroup}}
+	} else {
+		msg.PeerID = &tg.PeerUser{UserID: from}
+	}
+	return &ext.Update{EffectiveMessage: gotgtypes.ConstructMessage(msg), Entities: entities}
+}
+
+func TestAccessRefusal(t *testing.T) {
+	callback := func(from int64) *ext.Update {
+		return &ext.Update{CallbackQuery: &tg.UpdateBotCallbackQuery{UserID: from}}
+	}
+	inline := func(from int64) *ext.Update {
+		return &ext.Update{InlineQuery: &tg.UpdateBotInlineQuery{UserID: from}}
+	}
+
+	t.Run("no allow list", func(t *testing.T) {
+		ctx := accessTestContext(t, nil)
+		for name, tt := range map[string]struct {
+			update *ext.Update
+			want   string
+		}{
+			"private message":       {messageUpdate(testStrange, false, "hi"), ""},
+			"banned private":        {messageUpdate(testBanned, false, "hi"), "banned"},
+			"banned media in group": {messageUpdate(testBanned, true, ""), "banned"},
+			"banned callback":       {callback(testBanned), "banned"},
+			"banned inline":         {inline(testBanned), "banned"},
+			"no user":               {&ext.Update{}, ""},
+		} {
+			if got := accessRefusal(ctx, tt.update); got != tt.want {
+				t.Errorf("%s: accessRefusal = %q, want %q", name, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("allow list", func(t *testing.T) {
+		ctx := accessTestContext(t, []int64{testAllowed})
+		for name, tt := range map[string]struct {
+			update *ext.Update
+			want   string
+		}{
+			"admin":                  {messageUpdate(testAdmin, false, "hi"), ""},
+			"allowed":                {messageUpdate(testAllowed, false, "hi"), ""},
+			"other private":          {messageUpdate(testStrange, false, "hi"), "not_allowed"},
+			"other command in group": {messageUpdate(testStrange, true, "/start"), "not_allowed"},
+			// enabled by an allowed admin of the group
+			"other media in group": {messageUpdate(testStrange, true, ""), ""},
+			"other callback":       {callback(testStrange), "not_allowed"},
+			"other inline":         {inline(testStrange), "not_allowed"},
+			"banned stays banned":  {messageUpdate(testBanned, true, ""), "banned"},
+		} {
+			if got := accessRefusal(ctx, tt.update); got != tt.want {
+				t.Errorf("%s: accessRefusal = %q, want %q", name, got, tt.want)
+			}
+		}
+	})
+}
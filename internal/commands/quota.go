@@ -0,0 +1,187 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/cache"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/ext"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// limitDefault resets a /setlimit override to the default from the config.
+const limitDefault = "default"
+
+func (m *command) LoadQuota(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("quota")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("me", m.me))
+	dispatcher.AddHandler(handlers.NewCommand("setlimit", m.adminOnly("setlimit", m.setLimit)))
+}
+
+// reserveQuota counts a new file of the given size in the usage of the user
+// on day, before the file is processed. It returns the message template to
+// reply with if the file would exceed the quota of the user, or "" once the
+// file is counted; releaseQuota takes it back if processing fails.
+func reserveQuota(userID int64, day time.Time, fileSize int64) (string, map[string]interface{}) {
+	quota := database.GetUserQuota(userID)
+	if quota.MaxFileSize > 0 && fileSize > quota.MaxFileSize {
+		return "quota_file_size", map[string]interface{}{"Max": utils.FormatFileSizeShort(quota.MaxFileSize)}
+	}
+	reserved, err := database.ReserveUserFile(userID, day, fileSize, quota.FilesPerDay, quota.BytesPerDay)
+	if err != nil || reserved {
+		return "", nil
+	}
+	today, err := database.GetUserDayStats(userID, day)
+	if err != nil {
+		return "", nil
+	}
+	if quota.FilesPerDay > 0 && today.FileCount >= quota.FilesPerDay {
+		return "quota_files", map[string]interface{}{"Max": quota.FilesPerDay}
+	}
+	if quota.BytesPerDay > 0 && today.TotalSize+fileSize > quota.BytesPerDay {
+		return "quota_bytes", map[string]interface{}{
+			"Max":  utils.FormatFileSizeShort(quota.BytesPerDay),
+			"Left": utils.FormatFileSizeShort(max(quota.BytesPerDay-today.TotalSize, 0)),
+		}
+	}
+	return "", nil
+}
+
+// releaseQuota takes back a file counted by reserveQuota.
+func (m *command) releaseQuota(userID int64, day time.Time, fileSize int64) {
+	if err := database.ReleaseUserFile(userID, day, fileSize); err != nil {
+		m.log.Error("Failed to release user usage", zap.Error(err), zap.Int64("userID", userID))
+	}
+}
+
+// me shows the user their quota and what is left of it today.
+func (m *command) me(ctx *ext.Context, u *ext.Update) error {
+	userID := senderID(u)
+	quota := database.GetUserQuota(userID)
+	today, err := database.GetUserDayStats(userID, cache.StatsToday())
+	if err != nil {
+		m.log.Error("Failed to get user stats", zap.Error(err))
+	}
+	data := map[string]interface{}{
+		"UserID":     userID,
+		"Files":      today.FileCount,
+		"Size":       utils.FormatFileSizeShort(today.TotalSize),
+		"MaxFiles":   quota.FilesPerDay,
+		"MaxStreams": quota.MaxStreams,
+		// left empty when unlimited
+		"FilesLeft":   int64(0),
+		"MaxSize":     "",
+		"SizeLeft":    "",
+		"MaxFileSize": "",
+	}
+	if quota.FilesPerDay > 0 {
+		data["FilesLeft"] = max(quota.FilesPerDay-today.FileCount, 0)
+	}
+	if quota.BytesPerDay > 0 {
+		data["MaxSize"] = utils.FormatFileSizeShort(quota.BytesPerDay)
+		data["SizeLeft"] = utils.FormatFileSizeShort(max(quota.BytesPerDay-today.TotalSize, 0))
+	}
+	if quota.MaxFileSize > 0 {
+		data["MaxFileSize"] = utils.FormatFileSizeShort(quota.MaxFileSize)
+	}
+	reply(ctx, u, "me", data, nil)
+	return dispatcher.EndGroups
+}
+
+// setLimit overrides one limit of a user:
+//
+//	/setlimit <userID> files|bytes|size|streams <value|default>
+//
+// Sizes accept KB, MB, GB and TB suffixes, and 0 means unlimited.
+func (m *command) setLimit(ctx *ext.Context, u *ext.Update) error {
+	args := u.Args()
+	if len(args) < 4 {
+		reply(ctx, u, "setlimit_usage", nil, nil)
+		return dispatcher.EndGroups
+	}
+	userID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		reply(ctx, u, "setlimit_usage", nil, nil)
+		return dispatcher.EndGroups
+	}
+	limit := strings.ToLower(args[2])
+	var value *int64
+	if !strings.EqualFold(args[3], limitDefault) {
+		parse := strconv.Atoi
+		if limit == "bytes" || limit == "size" {
+			parse = parseSize
+		}
+		n, err := parse(args[3])
+		if err != nil || n < 0 {
+			reply(ctx, u, "setlimit_usage", nil, nil)
+			return dispatcher.EndGroups
+		}
+		v := int64(n)
+		value = &v
+	}
+
+	limits, err := database.GetUserLimits(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		limits, err = &types.UserLimits{UserID: userID}, nil
+	}
+	if err != nil {
+		m.log.Error("Failed to get user limits", zap.Error(err))
+		reply(ctx, u, "admin_failed", nil, nil)
+		return dispatcher.EndGroups
+	}
+	switch limit {
+	case "files":
+		limits.FilesPerDay = value
+	case "bytes":
+		limits.BytesPerDay = value
+	case "size":
+		limits.MaxFileSize = value
+	case "streams":
+		limits.MaxStreams = value
+	default:
+		reply(ctx, u, "setlimit_usage", nil, nil)
+		return dispatcher.EndGroups
+	}
+	limits.SetBy = senderID(u)
+	if err := database.SaveUserLimits(limits); err != nil {
+		m.log.Error("Failed to save user limits", zap.Error(err))
+		reply(ctx, u, "admin_failed", nil, nil)
+		return dispatcher.EndGroups
+	}
+	quota := database.GetUserQuota(userID)
+	reply(ctx, u, "setlimit_done", map[string]interface{}{
+		"UserID":      userID,
+		"Quota":       quota,
+		"BytesPerDay": utils.FormatFileSizeShort(quota.BytesPerDay),
+		"MaxFileSize": utils.FormatFileSizeShort(quota.MaxFileSize),
+	}, nil)
+	return dispatcher.EndGroups
+}
+
+// parseSize parses sizes like "500MB" or "2GB", in 1024 multiples.
+func parseSize(s string) (int, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := 1
+	for i, unit := range []string{"KB", "MB", "GB", "TB"} {
+		if strings.HasSuffix(s, unit) {
+			multiplier = 1 << (10 * (i + 1))
+			s = strings.TrimSuffix(s, unit)
+			break
+		}
+	}
+	s = strings.TrimSuffix(s, "B")
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int(n * float64(multiplier)), nil
+}
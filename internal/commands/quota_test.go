@@ -0,0 +1,42 @@
+package commands
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{in: "500", want: 500},
+		{in: "500B", want: 500},
+		{in: "1KB", want: 1 << 10},
+		{in: "500MB", want: 500 << 20},
+		{in: "2GB", want: 2 << 30},
+		{in: "1TB", want: 1 << 40},
+		{in: "1.5GB", want: 3 << 29},
+		{in: "2gb", want: 2 << 30},
+		{in: " 10MB ", want: 10 << 20},
+		{in: "0", want: 0},
+		{in: "", wantErr: true},
+		{in: "GB", wantErr: true},
+		{in: "ten", wantErr: true},
+		{in: "10XB", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseSize(%q) = %d, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSize(%q) returned %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseSize(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
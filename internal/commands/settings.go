@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/templates"
+	"EverythingSuckz/fsb/internal/types"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/dispatcher/handlers"
+	"github.com/celestix/gotgproto/dispatcher/handlers/filters"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/functions"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/telegram/message/markup"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+const settingsCallbackPrefix = "set:"
+
+// linkLifetimes are the choices of the link lifetime setting, in seconds.
+var linkLifetimes = []int64{0, 3600, 86400, 7 * 86400, 30 * 86400}
+
+func (m *command) LoadSettings(dispatcher dispatcher.Dispatcher) {
+	log := m.log.Named("settings")
+	defer log.Sugar().Info("Loaded")
+	dispatcher.AddHandler(handlers.NewCommand("settings", m.settings))
+	dispatcher.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(settingsCallbackPrefix), m.settingsCallback))
+}
+
+// settings shows the preferences of the user, each one a button that
+// changes it.
+func (m *command) settings(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	if ctx.PeerStorage.GetPeerById(chatId).Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	settings := userSettings(senderID(u))
+	lang := userLanguage(u)
+	reply(ctx, u, "settings", settings, &ext.ReplyOpts{Markup: settingsButtons(lang, settings)})
+	return dispatcher.EndGroups
+}
+
+func (m *command) settingsCallback(ctx *ext.Context, u *ext.Update) error {
+	settings := userSettings(u.CallbackQuery.UserID)
+	chatId := functions.GetChatIdFromPeer(u.CallbackQuery.Peer)
+	switch strings.TrimPrefix(string(u.CallbackQuery.Data), settingsCallbackPrefix) {
+	case "lifetime":
+		settings.LinkLifetime = nextLinkLifetime(settings.LinkLifetime)
+	case "format":
+		if settings.TextReplies() {
+			settings.ReplyFormat = types.ReplyFormatButtons
+		} else {
+			settings.ReplyFormat = types.ReplyFormatText
+		}
+	case "watch":
+		settings.HideWatchLink = !settings.HideWatchLink
+	case "library":
+		settings.NoLibrary = !settings.NoLibrary
+	case "language":
+		// the language has its own menu, shared with /language
+		text, entities, err := templates.RenderText(userLanguage(u), "language_choose", nil)
+		if err != nil {
+			m.log.Error("Failed to render message", zap.Error(err))
+			return dispatcher.EndGroups
+		}
+		_, _ = ctx.EditMessage(chatId, &tg.MessagesEditMessageRequest{
+			ID:          u.CallbackQuery.MsgID,
+			Message:     text,
+			Entities:    entities,
+			ReplyMarkup: languageButtons(userLanguage(u)),
+		})
+		_, _ = ctx.AnswerCallback(&tg.MessagesSetBotCallbackAnswerRequest{QueryID: u.CallbackQuery.QueryID})
+		return dispatcher.EndGroups
+	default:
+		answerCallback(ctx, u, "callback_invalid", true)
+		return dispatcher.EndGroups
+	}
+	if err := database.SaveUserSettings(settings); err != nil {
+		m.log.Error("Failed to save settings", zap.Error(err))
+		answerCallback(ctx, u, "settings_failed", true)
+		return dispatcher.EndGroups
+	}
+
+	lang := userLanguage(u)
+	text, entities, err := templates.RenderText(lang, "settings", settings)
+	if err != nil {
+		m.log.Error("Failed to render message", zap.Error(err))
+	} else {
+		_, _ = ctx.EditMessage(chatId, &tg.MessagesEditMessageRequest{
+			ID:          u.CallbackQuery.MsgID,
+			Message:     text,
+			Entities:    entities,
+			ReplyMarkup: settingsButtons(lang, settings),
+		})
+	}
+	answerCallback(ctx, u, "callback_settings_saved", false)
+	return dispatcher.EndGroups
+}
+
+func settingsButtons(lang string, settings *types.UserSettings) tg.ReplyMarkupClass {
+	button := func(name, data string) tg.KeyboardButtonRow {
+		return markup.Row(markup.Callback(templates.Plain(lang, name, settings), []byte(settingsCallbackPrefix+data)))
+	}
+	return markup.InlineKeyboard(
+		button("button_settings_lifetime", "lifetime"),
+		button("button_settings_format", "format"),
+		button("button_settings_watch", "watch"),
+		button("button_settings_library", "library"),
+		markup.Row(markup.Callback(
+			templates.Plain(lang, "button_settings_language", nil)+": "+templates.Plain(lang, "language_name", nil),
+			[]byte(settingsCallbackPrefix+"language"),
+		)),
+	)
+}
+
+// nextLinkLifetime returns the choice after the current one, going back to
+// the first after the last.
+func nextLinkLifetime(current int64) int64 {
+	for i, lifetime := range linkLifetimes {
+		if lifetime == current && i+1 < len(linkLifetimes) {
+			return linkLifetimes[i+1]
+		}
+	}
+	return linkLifetimes[0]
+}
+
+// userSettings returns the preferences of a user, or the defaults if they
+// have none.
+func userSettings(userID int64) *types.UserSettings {
+	settings, err := database.GetUserSettings(userID)
+	if err != nil {
+		return &types.UserSettings{UserID: userID}
+	}
+	return settings
+}
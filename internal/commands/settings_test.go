@@ -0,0 +1,25 @@
+package commands
+
+import "testing"
+
+func TestNextLinkLifetime(t *testing.T) {
+	tests := []struct {
+		current int64
+		want    int64
+	}{
+		{current: 0, want: 3600},
+		{current: 3600, want: 86400},
+		{current: 86400, want: 7 * 86400},
+		{current: 7 * 86400, want: 30 * 86400},
+		// the last choice wraps around to the first
+		{current: 30 * 86400, want: 0},
+		// values that aren't a choice, such as one removed since, start over
+		{current: 1234, want: 0},
+		{current: -1, want: 0},
+	}
+	for _, tt := range tests {
+		if got := nextLinkLifetime(tt.current); got != tt.want {
+			t.Errorf("nextLinkLifetime(%d) = %d, want %d", tt.current, got, tt.want)
+		}
+	}
+}
@@ -3,9 +3,13 @@ package commands
 import (
 	"EverythingSuckz/fsb/config"
 	"EverythingSuckz/fsb/internal/cache"
+	"EverythingSuckz/fsb/internal/database"
 	"EverythingSuckz/fsb/internal/types"
 	"EverythingSuckz/fsb/internal/utils"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/celestix/gotgproto/dispatcher"
@@ -18,6 +22,7 @@ func (m *command) LoadStats(dispatcher dispatcher.Dispatcher) {
 	log := m.log.Named("stats")
 	defer log.Sugar().Info("Loaded")
 	dispatcher.AddHandler(handlers.NewCommand("stats", stats))
+	dispatcher.AddHandler(handlers.NewCommand("linkstats", linkStats))
 }
 
 func stats(ctx *ext.Context, u *ext.Update) error {
@@ -26,59 +31,257 @@ func stats(ctx *ext.Context, u *ext.Update) error {
 	if peerChatId.Type != int(storage.TypeUser) {
 		return dispatcher.EndGroups
 	}
-	
-	// Check if user is allowed (if restrictions are enabled)
-	if len(config.ValueOf.AllowedUsers) != 0 && !utils.Contains(config.ValueOf.AllowedUsers, chatId) {
-		ctx.Reply(u, "You are not allowed to use this bot.", nil)
-		return dispatcher.EndGroups
-	}
 
 	// Get statistics
 	statsCache := cache.GetStatsCache()
 	if statsCache == nil {
-		ctx.Reply(u, "❌ Statistics service is not available at the moment.", nil)
+		reply(ctx, u, "stats_unavailable", nil, nil)
+		return dispatcher.EndGroups
+	}
+
+	if args := u.Args(); len(args) > 1 {
+		from, to, ok := statsPeriodRange(args[1], cache.StatsToday())
+		if !ok {
+			reply(ctx, u, "stats_usage", nil, nil)
+			return dispatcher.EndGroups
+		}
+		stats, err := statsCache.GetPeriodStats(from, to)
+		if err != nil {
+			reply(ctx, u, "stats_failed", nil, nil)
+			return dispatcher.EndGroups
+		}
+		reply(ctx, u, "stats_period", periodStatisticsMessageData(stats), nil)
 		return dispatcher.EndGroups
 	}
 
 	stats, err := statsCache.GetCompleteStats()
 	if err != nil {
 		// Log error but don't expose it to user
-		ctx.Reply(u, "❌ Failed to retrieve statistics. Please try again later.", nil)
+		reply(ctx, u, "stats_failed", nil, nil)
+		return dispatcher.EndGroups
+	}
+	// the message IDs of the top files lead to the files themselves
+	if !isAdmin(chatId) {
+		stats.TopFiles = nil
+	}
+
+	reply(ctx, u, "stats", statisticsMessageData(stats), nil)
+	return dispatcher.EndGroups
+}
+
+// statsPeriodRange returns the first and last days of a /stats period:
+// today, yesterday, week, month or year for the days up to today, a day as
+// YYYY-MM-DD, a month as YYYY-MM, or a range as YYYY-MM-DD..YYYY-MM-DD.
+func statsPeriodRange(period string, today time.Time) (time.Time, time.Time, bool) {
+	switch strings.ToLower(period) {
+	case "today":
+		return today, today, true
+	case "yesterday":
+		yesterday := today.AddDate(0, 0, -1)
+		return yesterday, yesterday, true
+	case "week":
+		return today.AddDate(0, 0, -6), today, true
+	case "month":
+		return today.AddDate(0, 0, -29), today, true
+	case "year":
+		return today.AddDate(0, 0, -364), today, true
+	}
+	if first, last, ok := strings.Cut(period, ".."); ok {
+		from, err := cache.ParseStatsDay(first)
+		if err != nil {
+			return from, from, false
+		}
+		to, err := cache.ParseStatsDay(last)
+		return from, to, err == nil && !to.Before(from)
+	}
+	if day, err := cache.ParseStatsDay(period); err == nil {
+		return day, day, true
+	}
+	if month, err := time.Parse("2006-01", period); err == nil {
+		return month, month.AddDate(0, 1, -1), true
+	}
+	return today, today, false
+}
+
+// statsPeriod is a period of the "stats" message template.
+type statsPeriod struct {
+	Files    int64
+	Size     string
+	Served   string
+	Requests int64
+	Viewers  int64
+}
+
+// statsFile is a file of the "stats" message template.
+type statsFile struct {
+	Rank      int
+	MessageID int
+	Served    string
+	Requests  int64
+	Viewers   int64
+}
+
+// statsMessageData is what the "stats" message template is executed with.
+type statsMessageData struct {
+	Today     statsPeriod
+	Yesterday statsPeriod
+	LastWeek  statsPeriod
+	Total     statsPeriod
+	TopFiles  []statsFile
+	UpdatedAt string
+}
+
+func statisticsMessageData(stats types.StatisticsResponse) statsMessageData {
+	period := func(fileCount, totalSize int64, traffic types.TrafficStats) statsPeriod {
+		return statsPeriod{
+			Files:    fileCount,
+			Size:     utils.FormatFileSizeShort(totalSize),
+			Served:   utils.FormatFileSizeShort(traffic.Bytes),
+			Requests: traffic.Requests,
+			Viewers:  traffic.UniqueViewers,
+		}
+	}
+	topFiles := make([]statsFile, 0, len(stats.TopFiles))
+	for i, file := range stats.TopFiles {
+		topFiles = append(topFiles, statsFile{
+			Rank:      i + 1,
+			MessageID: file.MessageID,
+			Served:    utils.FormatFileSizeShort(file.Bytes),
+			Requests:  file.Requests,
+			Viewers:   file.UniqueViewers,
+		})
+	}
+	return statsMessageData{
+		Today:     period(stats.Today.FileCount, stats.Today.TotalSize, stats.Today.Traffic),
+		Yesterday: period(stats.Yesterday.FileCount, stats.Yesterday.TotalSize, stats.Yesterday.Traffic),
+		LastWeek:  period(stats.LastWeek.FileCount, stats.LastWeek.TotalSize, stats.LastWeek.Traffic),
+		Total:     period(stats.Total.FileCount, stats.Total.TotalSize, stats.Total.Traffic),
+		TopFiles:  topFiles,
+		UpdatedAt: time.Now().In(cache.StatsLocation()).Format("2006-01-02 15:04:05"),
+	}
+}
+
+// periodStatsMessageData is what the "stats_period" message template is
+// executed with.
+type periodStatsMessageData struct {
+	From   string
+	To     string
+	Period statsPeriod
+}
+
+func periodStatisticsMessageData(stats types.PeriodStats) periodStatsMessageData {
+	return periodStatsMessageData{
+		From: stats.Start,
+		To:   stats.End,
+		Period: statsPeriod{
+			Files:    stats.FileCount,
+			Size:     utils.FormatFileSizeShort(stats.TotalSize),
+			Served:   utils.FormatFileSizeShort(stats.Traffic.Bytes),
+			Requests: stats.Traffic.Requests,
+			Viewers:  stats.Traffic.UniqueViewers,
+		},
+	}
+}
+
+// linkStats shows the views of one of the user's files, given its link or
+// message ID.
+func linkStats(ctx *ext.Context, u *ext.Update) error {
+	chatId := u.EffectiveChat().GetID()
+	if ctx.PeerStorage.GetPeerById(chatId).Type != int(storage.TypeUser) {
+		return dispatcher.EndGroups
+	}
+	args := u.Args()
+	if len(args) < 2 {
+		reply(ctx, u, "linkstats_usage", nil, nil)
+		return dispatcher.EndGroups
+	}
+	messageID, ok := linkMessageID(args[1])
+	if !ok {
+		reply(ctx, u, "linkstats_usage", nil, nil)
+		return dispatcher.EndGroups
+	}
+	file, err := database.GetUserFile(messageID)
+	if err != nil || (file.OwnerID != senderID(u) && !isAdmin(senderID(u))) {
+		reply(ctx, u, "linkstats_not_found", nil, nil)
 		return dispatcher.EndGroups
 	}
 
-	// Format the statistics message
-	message := formatStatisticsMessage(stats)
-	
-	ctx.Reply(u, message, nil)
+	statsCache := cache.GetStatsCache()
+	if statsCache == nil {
+		reply(ctx, u, "stats_unavailable", nil, nil)
+		return dispatcher.EndGroups
+	}
+	stats, err := statsCache.GetLinkStats(messageID)
+	if err != nil {
+		reply(ctx, u, "stats_failed", nil, nil)
+		return dispatcher.EndGroups
+	}
+	stats.FileName = file.FileName
+	reply(ctx, u, "linkstats", linkStatisticsMessageData(stats, file.OwnerID), nil)
 	return dispatcher.EndGroups
 }
 
-func formatStatisticsMessage(stats types.StatisticsResponse) string {
-	message := "📊 Bot Statistics\n\n"
-	
-	// Today's stats
-	message += fmt.Sprintf("Today: %d files - %s\n", 
-		stats.Today.FileCount, 
-		utils.FormatFileSizeShort(stats.Today.TotalSize))
-	
-	// Yesterday's stats
-	message += fmt.Sprintf("Yesterday: %d files - %s\n", 
-		stats.Yesterday.FileCount, 
-		utils.FormatFileSizeShort(stats.Yesterday.TotalSize))
-	
-	// Last 7 days stats
-	message += fmt.Sprintf("Last 7 days: %d files - %s\n", 
-		stats.LastWeek.FileCount, 
-		utils.FormatFileSizeShort(stats.LastWeek.TotalSize))
-	
-	// Total stats
-	message += fmt.Sprintf("All time: %d files - %s\n\n", 
-		stats.Total.FileCount, 
-		utils.FormatFileSizeShort(stats.Total.TotalSize))
-	
-	message += "🔄 Stats are updated in real-time\n"
-	message += "⏰ Last updated: " + time.Now().Format("2006-01-02 15:04:05") + "."
-	
-	return message
-} 
+// linkMessageID returns the log channel message ID of a stream, download or
+// watch link, or of a bare message ID.
+func linkMessageID(arg string) (int, bool) {
+	if messageID, err := strconv.Atoi(arg); err == nil {
+		return messageID, true
+	}
+	link, err := url.Parse(arg)
+	if err != nil {
+		return 0, false
+	}
+	for _, segment := range strings.Split(link.Path, "/") {
+		if messageID, err := strconv.Atoi(segment); err == nil {
+			return messageID, true
+		}
+	}
+	return 0, false
+}
+
+// linkStatsDay is a day of the "linkstats" message template.
+type linkStatsDay struct {
+	Date     string
+	Viewers  int64
+	Requests int64
+	Served   string
+}
+
+// linkStatsMessageData is what the "linkstats" message template is executed
+// with.
+type linkStatsMessageData struct {
+	FileName  string
+	Since     string // "" when views are kept forever
+	Viewers   int64
+	Requests  int64
+	Served    string
+	Days      []linkStatsDay // the last 7 days with views, newest first
+	Referrers []types.SourceCount
+	Countries []types.SourceCount
+	APIURL    string
+}
+
+func linkStatisticsMessageData(stats types.LinkStats, ownerID int64) linkStatsMessageData {
+	data := linkStatsMessageData{
+		FileName:  stats.FileName,
+		Viewers:   stats.Total.UniqueViewers,
+		Requests:  stats.Total.Requests,
+		Served:    utils.FormatFileSizeShort(stats.Total.Bytes),
+		Referrers: stats.Referrers,
+		Countries: stats.Countries,
+		APIURL: fmt.Sprintf("%s/api/links/%d/stats?token=%s",
+			strings.TrimSuffix(config.ValueOf.Host, "/"), stats.MessageID, utils.LinkStatsToken(stats.MessageID, ownerID)),
+	}
+	if !stats.Since.IsZero() {
+		data.Since = stats.Since.Format("2006-01-02")
+	}
+	for _, day := range stats.Days[:min(len(stats.Days), 7)] {
+		data.Days = append(data.Days, linkStatsDay{
+			Date:     day.Date.Format("2006-01-02"),
+			Viewers:  day.UniqueViewers,
+			Requests: day.Requests,
+			Served:   utils.FormatFileSizeShort(day.Bytes),
+		})
+	}
+	return data
+}
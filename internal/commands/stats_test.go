@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatsPeriodRange(t *testing.T) {
+	day := func(value string) time.Time {
+		d, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	today := day("2024-03-01")
+	tests := []struct {
+		period   string
+		from, to string
+		ok       bool
+	}{
+		{period: "today", from: "2024-03-01", to: "2024-03-01", ok: true},
+		{period: "TODAY", from: "2024-03-01", to: "2024-03-01", ok: true},
+		{period: "yesterday", from: "2024-02-29", to: "2024-02-29", ok: true},
+		{period: "week", from: "2024-02-24", to: "2024-03-01", ok: true},
+		{period: "month", from: "2024-02-01", to: "2024-03-01", ok: true},
+		{period: "year", from: "2023-03-03", to: "2024-03-01", ok: true},
+		{period: "2024-02-29", from: "2024-02-29", to: "2024-02-29", ok: true},
+		{period: "2024-02", from: "2024-02-01", to: "2024-02-29", ok: true},
+		{period: "2023-02", from: "2023-02-01", to: "2023-02-28", ok: true},
+		{period: "2023-12", from: "2023-12-01", to: "2023-12-31", ok: true},
+		{period: "2024-01-01..2024-01-31", from: "2024-01-01", to: "2024-01-31", ok: true},
+		{period: "2024-01-01..2024-01-01", from: "2024-01-01", to: "2024-01-01", ok: true},
+		{period: "2024-01-31..2024-01-01", ok: false},
+		{period: "2024-01-01..", ok: false},
+		{period: "..2024-01-01", ok: false},
+		{period: "2023-02-29", ok: false},
+		{period: "2024-13", ok: false},
+		{period: "last", ok: false},
+	}
+	for _, tt := range tests {
+		from, to, ok := statsPeriodRange(tt.period, today)
+		if ok != tt.ok {
+			t.Errorf("statsPeriodRange(%q) ok = %v, want %v", tt.period, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if !from.Equal(day(tt.from)) || !to.Equal(day(tt.to)) {
+			t.Errorf("statsPeriodRange(%q) = %s..%s, want %s..%s", tt.period,
+				from.Format("2006-01-02"), to.Format("2006-01-02"), tt.from, tt.to)
+		}
+	}
+}
@@ -1,25 +1,69 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
-	"strings"
+	"time"
 
 	"EverythingSuckz/fsb/config"
 	"EverythingSuckz/fsb/internal/cache" // Ahora sí se usa
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
 	"EverythingSuckz/fsb/internal/utils"
 
 	"github.com/celestix/gotgproto/dispatcher"
 	"github.com/celestix/gotgproto/dispatcher/handlers"
 	"github.com/celestix/gotgproto/ext"
 	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/telegram/message/markup"
 	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
 )
 
 // LoadStream registra el handler.
 // Pasamos 'nil' como filtro inicial y validamos dentro de sendLink para máximo control.
+// Va en el grupo 1 para que los comandos del grupo 0 tengan prioridad.
 func (m *command) LoadStream(dispatcher dispatcher.Dispatcher) {
 	m.log.Named("stream").Info("Streaming handler initialized")
-	dispatcher.AddHandler(handlers.NewMessage(nil, m.sendLink))
+	dispatcher.AddHandlerToGroup(handlers.NewMessage(nil, m.sendLink), 1)
+}
+
+// linkMessageData es con lo que se ejecutan las plantillas "link", "link_text"
+// e "inline_result".
+type linkMessageData struct {
+	FileName    string
+	FileSize    string
+	StreamURL   string
+	DownloadURL string
+	WatchURL    string // vacío si el dueño lo desactivó en /settings
+	ExpiresAt   string // vacío si el enlace no caduca
+}
+
+// linkMessage prepara la respuesta de un archivo según los /settings de su
+// dueño: la plantilla a usar, sus datos y el teclado. Con VIEWER_TOKENS los
+// enlaces llevan un token emitido a viewerID.
+func linkMessage(lang string, viewerID int64, file *types.UserFile) (string, linkMessageData, tg.ReplyMarkupClass) {
+	settings := userSettings(file.OwnerID)
+	token := utils.NewViewerToken(viewerID, utils.FileScope(file.MessageID))
+	data := linkMessageData{
+		FileName:    file.FileName,
+		FileSize:    formatFileSize(file.FileSize),
+		StreamURL:   utils.WithViewerToken(utils.GetStreamLink(file.MessageID, file.Hash), token),
+		DownloadURL: utils.WithViewerToken(utils.GetDownloadLink(file.MessageID, file.Hash), token),
+	}
+	if !settings.HideWatchLink {
+		data.WatchURL = utils.WithViewerToken(utils.GetWatchLink(file.MessageID, file.Hash), token)
+	}
+	if file.ExpiresAt != nil {
+		data.ExpiresAt = file.ExpiresAt.Format("2006-01-02 15:04 MST")
+	}
+	if !settings.TextReplies() {
+		return "link", data, linkButtons(lang, viewerID, file, settings)
+	}
+	if token != "" {
+		return "link_text", data, markup.InlineKeyboard(refreshButtonRow(lang, file.MessageID))
+	}
+	return "link_text", data, nil
 }
 
 // formatFileSize convierte bytes a texto legible.
@@ -37,15 +81,22 @@ func formatFileSize(bytes int64) string {
 
 // sendLink es el handler principal que valida, procesa y responde.
 func (m *command) sendLink(ctx *ext.Context, u *ext.Update) error {
-	// 1. Validación de Chat Privado
+	// 1. Validación del Chat: privados, o grupos/canales habilitados con /enable
 	chatId := u.EffectiveChat().GetID()
+	var chatSettings *types.ChatSettings
 	if ctx.PeerStorage.GetPeerById(chatId).Type != int(storage.TypeUser) {
-		return dispatcher.EndGroups
+		settings, err := database.GetChatSettings(chatId)
+		if err != nil || !settings.Enabled || settings.Mode != types.ChatModeReply {
+			// los modos caption y buttons los maneja channelPost
+			return dispatcher.EndGroups
+		}
+		chatSettings = settings
 	}
 
 	// 2. Validación de Tipo de Media (Reemplaza al filtro anterior)
 	// Si no tiene media o no es documento/foto, ignoramos silenciosamente.
-	if u.EffectiveMessage.Media == nil {
+	// Las ediciones (incluidas las nuestras en canales) no generan enlaces nuevos.
+	if u.EffectiveMessage.Media == nil || u.EffectiveMessage.EditDate != 0 {
 		return dispatcher.EndGroups
 	}
 	switch u.EffectiveMessage.Media.(type) {
@@ -54,69 +105,143 @@ func (m *command) sendLink(ctx *ext.Context, u *ext.Update) error {
 	default:
 		return dispatcher.EndGroups
 	}
+	if chatSettings != nil && !chatSettings.AllowsMedia(utils.MediaType(u.EffectiveMessage.Media)) {
+		return dispatcher.EndGroups
+	}
 
-	// 3. Validación de Suscripción (Force Sub)
-	if config.ValueOf.ForceSubChannel != "" {
-		isSubscribed, err := utils.IsUserSubscribed(ctx, ctx.Raw, ctx.PeerStorage, chatId)
-		if err != nil || !isSubscribed {
-			joinURL := fmt.Sprintf("https://t.me/%s", config.ValueOf.ForceSubChannel)
-			_, _ = ctx.Reply(u, "⚠️ **Subscription Required**\n\nPlease join our channel:\n"+joinURL, &ext.ReplyOpts{NoWebpage: true})
+	// 3. Registro del usuario para /broadcast y validación de Suscripción
+	// (Force Sub), solo en chats privados
+	if chatSettings == nil {
+		m.recordUser(u)
+		if !m.checkForceSub(ctx, u, chatId) {
 			return dispatcher.EndGroups
 		}
 	}
 
-	// 4. Reenvío al Canal de Logs (Persistencia)
-	update, err := utils.ForwardMessages(ctx, chatId, config.ValueOf.LogChannelID, u.EffectiveMessage.ID)
+	// 4. Cuotas del usuario: tamaño máximo, archivos y bytes por día. El
+	// archivo se cuenta antes del reenvío, para que los de un álbum no pasen
+	// todos la comprobación a la vez, y se descuenta si el reenvío falla
+	ownerID := senderID(u)
+	media, err := utils.FileFromMedia(u.EffectiveMessage.Media)
 	if err != nil {
-		// Logueamos usando la interfaz interna del logger si falla
-		_, _ = ctx.Reply(u, "❌ Error: Could not forward file to log channel.", nil)
+		m.log.Error("Failed to read file of message", zap.Error(err))
+		reply(ctx, u, "forward_failed", nil, nil)
 		return dispatcher.EndGroups
 	}
-
-	// Extracción segura de datos
-	msgID := update.Updates[0].(*tg.UpdateMessageID).ID
-	channelMsg, ok := update.Updates[1].(*tg.UpdateNewChannelMessage).Message.(*tg.Message)
-	if !ok {
+	day := cache.StatsToday()
+	if name, data := reserveQuota(ownerID, day, media.FileSize); name != "" {
+		reply(ctx, u, name, data, &ext.ReplyOpts{ReplyToMessageId: u.EffectiveMessage.ID})
 		return dispatcher.EndGroups
 	}
 
-	file, err := utils.FileFromMedia(channelMsg.Media)
+	// 5. Reenvío al Canal de Logs (Persistencia)
+	msgID, file, err := forwardToLogChannel(ctx, chatId, u.EffectiveMessage.ID)
 	if err != nil {
+		m.log.Error("Failed to forward file to log channel", zap.Error(err))
+		m.releaseQuota(ownerID, day, media.FileSize)
+		reply(ctx, u, "forward_failed", nil, nil)
 		return dispatcher.EndGroups
 	}
 
-	// 5. Generación del Enlace RESTful
+	// 6. Generación del Enlace RESTful
 	fullHash := utils.PackFile(file.FileName, file.FileSize, file.MimeType, file.ID)
-	shortHash := utils.GetShortHash(fullHash)
-	baseUrl := strings.TrimSuffix(config.ValueOf.WorkerURL, "/")
-	
-	finalURL := fmt.Sprintf("%s/%d/%s", baseUrl, msgID, shortHash)
 
-	// 6. Registro de Estadísticas (Uso correcto del paquete cache)
+	// Registro del archivo y de su playlist (álbum o bundle abierto)
+	var postedIn int64
+	if chatSettings != nil {
+		postedIn = chatId
+	}
+	userFile := m.registerFile(ownerID, postedIn, msgID, fullHash, file, u.EffectiveMessage.GroupedID)
+
+	// 7. Registro de Estadísticas (Uso correcto del paquete cache); el uso
+	// diario del usuario ya se contó en el paso 4
 	if stats := cache.GetStatsCache(); stats != nil {
 		// Ignoramos el error de registro para no detener el flujo principal
 		_ = stats.RecordFileProcessed(file.FileSize)
 	}
 
-	// 7. Respuesta al Usuario
-	caption := fmt.Sprintf(
-		"🎬 **File:** `%s`\n"+
-			"💾 **Size:** `%s`\n\n"+
-			"🚀 **Direct Link:**\n`%s`\n\n"+
-			"⚡ *By @yoelbots*",
-		file.FileName, formatFileSize(file.FileSize), finalURL,
-	)
-
-	// Usamos markdown style parse mode explícitamente si ReplyOpts lo permite, 
-	// o confiamos en que gotgproto detecta las entidades.
-	// Nota: Si usas la última versión de gotgproto, 'styling' package es preferido,
-	// pero aquí usamos strings planos con formato markdown para evitar deps complejas.
-	_, _ = ctx.Reply(u, caption, &ext.ReplyOpts{
+	// 8. Respuesta al Usuario con la plantilla "link" (HTML con entidades reales)
+	// y los botones según LINK_BUTTONS (los álbumes ofrecen también la playlist),
+	// o con "link_text" si el usuario prefiere solo texto en /settings
+	lang := userLanguage(u)
+	name, data, buttons := linkMessage(lang, ownerID, userFile)
+	reply(ctx, u, name, data, &ext.ReplyOpts{
 		NoWebpage:        true,
+		Markup:           buttons,
 		ReplyToMessageId: u.EffectiveMessage.ID,
-		// ParseMode es manejado a veces automáticamente o requiere setup en main. 
-		// Si falla el formato, considera usar utils.Format o styling.
 	})
-	
+
 	return dispatcher.EndGroups
 }
+
+// forwardToLogChannel reenvía el mensaje al canal de logs y devuelve el ID
+// del mensaje reenviado junto con el archivo que contiene.
+func forwardToLogChannel(ctx *ext.Context, chatId int64, messageID int) (int, *types.File, error) {
+	update, err := utils.ForwardMessages(ctx, chatId, config.ValueOf.LogChannelID, messageID)
+	if err != nil {
+		return 0, nil, err
+	}
+	// Extracción segura de datos
+	var msgID int
+	var channelMsg *tg.Message
+	for _, upd := range update.Updates {
+		switch upd := upd.(type) {
+		case *tg.UpdateMessageID:
+			msgID = upd.ID
+		case *tg.UpdateNewChannelMessage:
+			channelMsg, _ = upd.Message.(*tg.Message)
+		}
+	}
+	if msgID == 0 || channelMsg == nil {
+		return 0, nil, errors.New("unexpected forward response")
+	}
+	file, err := utils.FileFromMedia(channelMsg.Media)
+	if err != nil {
+		return 0, nil, err
+	}
+	return msgID, file, nil
+}
+
+// registerFile guarda el archivo en el registro del usuario y lo añade a la
+// playlist del álbum o al bundle abierto. La caducidad y la biblioteca
+// siguen los /settings del dueño. chatID es el grupo o canal donde se
+// publicó, 0 en chats privados.
+func (m *command) registerFile(ownerID, chatID int64, msgID int, fullHash string, file *types.File, groupedID int64) *types.UserFile {
+	log := m.log.Named("registry")
+	var playlist *types.Playlist
+	var err error
+	if groupedID != 0 {
+		playlist, err = database.GetAlbumPlaylist(ownerID, groupedID)
+	} else if bundle, bundleErr := database.GetOpenBundle(ownerID); bundleErr == nil {
+		playlist = bundle
+	}
+	if err != nil {
+		log.Error("Failed to get album playlist", zap.Error(err))
+	}
+	userFile := &types.UserFile{
+		OwnerID:   ownerID,
+		ChatID:    chatID,
+		MessageID: msgID,
+		Hash:      fullHash,
+		FileName:  file.FileName,
+		FileSize:  file.FileSize,
+		MimeType:  file.MimeType,
+		Duration:  file.Duration,
+		Title:     file.Title,
+		HasThumb:  file.Thumb != "",
+		GroupedID: groupedID,
+	}
+	if playlist != nil {
+		userFile.PlaylistID = playlist.ID
+	}
+	settings := userSettings(ownerID)
+	if settings.LinkLifetime > 0 {
+		expiresAt := time.Now().Add(time.Duration(settings.LinkLifetime) * time.Second)
+		userFile.ExpiresAt = &expiresAt
+	}
+	userFile.Hidden = settings.NoLibrary
+	if err := database.AddUserFile(userFile); err != nil {
+		log.Error("Failed to register file", zap.Error(err))
+	}
+	return userFile
+}
@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/templates"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/functions"
+	"github.com/gotd/td/telegram/message/markup"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// refreshStartPrefix is the /start payload of the deep links that refresh
+// buttons in groups and channels open, as in "/start vt_123".
+const refreshStartPrefix = "vt_"
+
+func refreshButtonRow(lang string, messageID int) tg.KeyboardButtonRow {
+	return markup.Row(markup.Callback(
+		templates.Plain(lang, "button_refresh", nil),
+		[]byte(refreshCallbackPrefix+strconv.Itoa(messageID)),
+	))
+}
+
+// refreshURLButtonRow is refreshButtonRow for messages the bot can't get
+// callbacks from, such as inline results. It opens a private chat with the
+// bot, which replies with a new link.
+func refreshURLButtonRow(lang, botUsername string, messageID int) tg.KeyboardButtonRow {
+	return markup.Row(markup.URL(
+		templates.Plain(lang, "button_refresh", nil),
+		refreshDeepLink(botUsername, messageID),
+	))
+}
+
+func refreshDeepLink(botUsername string, messageID int) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%d", botUsername, refreshStartPrefix, messageID)
+}
+
+// refreshLink gives the user who tapped the refresh button new viewer
+// tokens. In private chats the link message is updated in place; elsewhere
+// the message is shared, so the user is sent to a private chat with the bot
+// instead.
+func (m *command) refreshLink(ctx *ext.Context, u *ext.Update) error {
+	messageID, err := strconv.Atoi(strings.TrimPrefix(string(u.CallbackQuery.Data), refreshCallbackPrefix))
+	if err != nil || !config.ValueOf.ViewerTokens {
+		answerCallback(ctx, u, "callback_invalid", true)
+		return dispatcher.EndGroups
+	}
+	if _, ok := u.CallbackQuery.Peer.(*tg.PeerUser); !ok {
+		_, _ = ctx.AnswerCallback(&tg.MessagesSetBotCallbackAnswerRequest{
+			QueryID: u.CallbackQuery.QueryID,
+			URL:     refreshDeepLink(ctx.Self.Username, messageID),
+		})
+		return dispatcher.EndGroups
+	}
+	file, ok := liveFile(messageID)
+	if !ok {
+		answerCallback(ctx, u, "callback_missing", true)
+		return dispatcher.EndGroups
+	}
+	userID := u.CallbackQuery.UserID
+	if !m.canRefresh(ctx, userID, file) {
+		answerCallback(ctx, u, "callback_refresh_denied", true)
+		return dispatcher.EndGroups
+	}
+	missing, err := utils.MissingSubscriptions(ctx, ctx.Raw, ctx.PeerStorage, userID)
+	if err != nil {
+		m.log.Error("Failed to check force-sub channels", zap.Error(err), zap.Int64("userID", userID))
+		answerCallback(ctx, u, "force_sub_failed", true)
+		return dispatcher.EndGroups
+	}
+	if len(missing) != 0 {
+		answerCallback(ctx, u, "callback_force_sub_missing", true)
+		return dispatcher.EndGroups
+	}
+
+	lang := userLanguage(u)
+	name, data, buttons := linkMessage(lang, userID, file)
+	text, entities, err := templates.RenderText(lang, name, data)
+	if err != nil {
+		m.log.Error("Failed to render message", zap.Error(err))
+		return dispatcher.EndGroups
+	}
+	chatId := functions.GetChatIdFromPeer(u.CallbackQuery.Peer)
+	_, _ = ctx.EditMessage(chatId, &tg.MessagesEditMessageRequest{
+		ID:          u.CallbackQuery.MsgID,
+		Message:     text,
+		Entities:    entities,
+		NoWebpage:   true,
+		ReplyMarkup: buttons,
+	})
+	answerCallback(ctx, u, "callback_link_refreshed", false)
+	return dispatcher.EndGroups
+}
+
+// sendFreshLink answers "/start vt_<messageID>" with a new link for the user.
+func (m *command) sendFreshLink(ctx *ext.Context, u *ext.Update, payload string) {
+	messageID, err := strconv.Atoi(strings.TrimPrefix(payload, refreshStartPrefix))
+	if err != nil || !config.ValueOf.ViewerTokens {
+		reply(ctx, u, "start", nil, nil)
+		return
+	}
+	userID := u.EffectiveChat().GetID()
+	if !m.checkForceSub(ctx, u, userID) {
+		return
+	}
+	file, ok := liveFile(messageID)
+	if !ok {
+		reply(ctx, u, "callback_missing", nil, nil)
+		return
+	}
+	if !m.canRefresh(ctx, userID, file) {
+		reply(ctx, u, "callback_refresh_denied", nil, nil)
+		return
+	}
+	name, data, buttons := linkMessage(userLanguage(u), userID, file)
+	reply(ctx, u, name, data, &ext.ReplyOpts{
+		NoWebpage: true,
+		Markup:    buttons,
+	})
+}
+
+// canRefresh reports whether userID may get new links for a file: its
+// owner, the bot admins and the members of the chat it was posted in.
+func (m *command) canRefresh(ctx *ext.Context, userID int64, file *types.UserFile) bool {
+	if file.OwnerID == userID || isAdmin(userID) {
+		return true
+	}
+	chatID := fileChat(file)
+	member, err := isUserChatMember(ctx, chatID, userID)
+	if err != nil {
+		m.log.Error("Failed to check chat member", zap.Error(err), zap.Int64("chatID", chatID))
+	}
+	return member
+}
+
+// liveFile returns the registered file of a message if its links can still
+// be served.
+func liveFile(messageID int) (*types.UserFile, bool) {
+	file, err := database.GetUserFile(messageID)
+	if err != nil {
+		return nil, false
+	}
+	if _, err := database.CheckLink(messageID); err != nil {
+		return nil, false
+	}
+	return file, true
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrLinkRevoked = errors.New("this link was revoked")
+	ErrLinkExpired = errors.New("this link has expired")
+	ErrOwnerBanned = errors.New("the owner of this file is banned")
+)
+
+// access keeps the ban and allow lists in memory, since they are checked on
+// every update and stream request. Changes are written to the DB first.
+var access = struct {
+	sync.RWMutex
+	banned  map[int64]bool
+	allowed map[int64]bool
+}{
+	banned:  make(map[int64]bool),
+	allowed: make(map[int64]bool),
+}
+
+// loadAccessLists lee las listas de baneados y permitidos de la DB
+func loadAccessLists() error {
+	var banned []types.BannedUser
+	if err := instance.Find(&banned).Error; err != nil {
+		return err
+	}
+	var allowed []types.AllowedUser
+	if err := instance.Find(&allowed).Error; err != nil {
+		return err
+	}
+	access.Lock()
+	defer access.Unlock()
+	for _, user := range banned {
+		access.banned[user.UserID] = true
+	}
+	for _, user := range allowed {
+		access.allowed[user.UserID] = true
+	}
+	return nil
+}
+
+// BanUser adds a user to the ban list, replacing any previous ban.
+func BanUser(ban *types.BannedUser) error {
+	if err := instance.Save(ban).Error; err != nil {
+		return err
+	}
+	access.Lock()
+	access.banned[ban.UserID] = true
+	access.Unlock()
+	return nil
+}
+
+// UnbanUser removes a user from the ban list and reports whether they were banned.
+func UnbanUser(userID int64) (bool, error) {
+	result := instance.Where("user_id = ?", userID).Delete(&types.BannedUser{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	access.Lock()
+	delete(access.banned, userID)
+	access.Unlock()
+	return result.RowsAffected != 0, nil
+}
+
+// IsBanned reports whether a user is in the ban list.
+func IsBanned(userID int64) bool {
+	access.RLock()
+	defer access.RUnlock()
+	return access.banned[userID]
+}
+
+// GetBannedUsers returns the ban list, newest first.
+func GetBannedUsers() ([]types.BannedUser, error) {
+	var banned []types.BannedUser
+	err := instance.Order("created_at DESC").Find(&banned).Error
+	return banned, err
+}
+
+// CountBannedUsers returns the size of the ban list.
+func CountBannedUsers() (int64, error) {
+	access.RLock()
+	defer access.RUnlock()
+	return int64(len(access.banned)), nil
+}
+
+// AllowUser adds a user to the allow list.
+func AllowUser(user *types.AllowedUser) error {
+	if err := instance.Save(user).Error; err != nil {
+		return err
+	}
+	access.Lock()
+	access.allowed[user.UserID] = true
+	access.Unlock()
+	return nil
+}
+
+// DisallowUser removes a user from the allow list and reports whether they were in it.
+func DisallowUser(userID int64) (bool, error) {
+	result := instance.Where("user_id = ?", userID).Delete(&types.AllowedUser{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	access.Lock()
+	delete(access.allowed, userID)
+	access.Unlock()
+	return result.RowsAffected != 0, nil
+}
+
+// IsAllowed reports whether a user is in the allow list.
+func IsAllowed(userID int64) bool {
+	access.RLock()
+	defer access.RUnlock()
+	return access.allowed[userID]
+}
+
+// HasAllowList reports whether any user was added to the allow list.
+func HasAllowList() bool {
+	access.RLock()
+	defer access.RUnlock()
+	return len(access.allowed) != 0
+}
+
+// GetAllowedUsers returns the allow list, newest first.
+func GetAllowedUsers() ([]types.AllowedUser, error) {
+	var allowed []types.AllowedUser
+	err := instance.Order("created_at DESC").Find(&allowed).Error
+	return allowed, err
+}
+
+// CheckLink returns the owner of a log channel message, and ErrLinkRevoked,
+// ErrLinkExpired or ErrOwnerBanned if its links must not be served. Files processed before
+// the registry existed have no owner and are always served. Other errors come
+// from the DB, and the links must not be served either.
+func CheckLink(messageID int) (int64, error) {
+	file, err := GetUserFile(messageID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if file.Revoked {
+		return file.OwnerID, ErrLinkRevoked
+	}
+	if file.ExpiresAt != nil && time.Now().After(*file.ExpiresAt) {
+		return file.OwnerID, ErrLinkExpired
+	}
+	if IsBanned(file.OwnerID) {
+		return file.OwnerID, ErrOwnerBanned
+	}
+	return file.OwnerID, nil
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckLink(t *testing.T) {
+	useTestDB(t)
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	for _, file := range []types.UserFile{
+		{MessageID: 1, OwnerID: 10},
+		{MessageID: 2, OwnerID: 10, Revoked: true},
+		{MessageID: 3, OwnerID: 10, ExpiresAt: &past},
+		{MessageID: 4, OwnerID: 10, ExpiresAt: &future},
+		{MessageID: 5, OwnerID: 20},
+		// revoked wins over the banned owner
+		{MessageID: 6, OwnerID: 20, Revoked: true},
+	} {
+		file.Hash = "hash"
+		file.FileName = "file.mp4"
+		if err := AddUserFile(&file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := BanUser(&types.BannedUser{UserID: 20}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		messageID int
+		owner     int64
+		err       error
+	}{
+		{messageID: 1, owner: 10},
+		{messageID: 2, owner: 10, err: ErrLinkRevoked},
+		{messageID: 3, owner: 10, err: ErrLinkExpired},
+		{messageID: 4, owner: 10},
+		{messageID: 5, owner: 20, err: ErrOwnerBanned},
+		{messageID: 6, owner: 20, err: ErrLinkRevoked},
+		// processed before the registry existed
+		{messageID: 99, owner: 0},
+	}
+	for _, tt := range tests {
+		owner, err := CheckLink(tt.messageID)
+		if owner != tt.owner || !errors.Is(err, tt.err) {
+			t.Errorf("CheckLink(%d) = %d, %v; want %d, %v", tt.messageID, owner, err, tt.owner, tt.err)
+		}
+	}
+
+	if _, err := UnbanUser(20); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CheckLink(5); err != nil {
+		t.Errorf("CheckLink after unban = %v, want nil", err)
+	}
+}
+
+func TestCheckLinkDatabaseError(t *testing.T) {
+	db := useTestDB(t)
+	if err := db.Migrator().DropTable(&types.UserFile{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CheckLink(1); err == nil {
+		t.Error("CheckLink without the files table = nil, want an error so the link isn't served")
+	}
+}
+
+func TestAccessListsReload(t *testing.T) {
+	useTestDB(t)
+	if err := BanUser(&types.BannedUser{UserID: 1, Reason: "spam"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AllowUser(&types.AllowedUser{UserID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AllowUser(&types.AllowedUser{UserID: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := DisallowUser(3); err != nil || !removed {
+		t.Fatalf("DisallowUser(3) = %v, %v; want true", removed, err)
+	}
+	if removed, err := DisallowUser(3); err != nil || removed {
+		t.Fatalf("DisallowUser(3) again = %v, %v; want false", removed, err)
+	}
+
+	// a restart only has what was written to the database
+	resetAccessLists()
+	if err := loadAccessLists(); err != nil {
+		t.Fatal(err)
+	}
+	if !IsBanned(1) || IsBanned(2) {
+		t.Errorf("IsBanned after reload = %v, %v; want true, false", IsBanned(1), IsBanned(2))
+	}
+	if !IsAllowed(2) || IsAllowed(3) || !HasAllowList() {
+		t.Errorf("IsAllowed after reload = %v, %v, HasAllowList = %v; want true, false, true", IsAllowed(2), IsAllowed(3), HasAllowList())
+	}
+}
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+)
+
+// CountUsers returns how many users have generated links.
+func CountUsers() (int64, error) {
+	var count int64
+	err := instance.Model(&types.UserFile{}).Distinct("owner_id").Count(&count).Error
+	return count, err
+}
+
+// TopUsers returns the users with the most files.
+func TopUsers(limit int) ([]types.UserUsage, error) {
+	var usage []types.UserUsage
+	err := instance.Model(&types.UserFile{}).
+		Select("owner_id, COUNT(*) AS files, COALESCE(SUM(file_size), 0) AS bytes").
+		Group("owner_id").
+		Order("files DESC").
+		Limit(limit).
+		Scan(&usage).Error
+	return usage, err
+}
+
+// AddAuditLog records an admin action.
+func AddAuditLog(entry *types.AuditLog) error {
+	return instance.Create(entry).Error
+}
@@ -0,0 +1,20 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+)
+
+// GetChatSettings returns the settings of a group or channel.
+func GetChatSettings(chatID int64) (*types.ChatSettings, error) {
+	var settings types.ChatSettings
+	err := instance.Where("chat_id = ?", chatID).First(&settings).Error
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// SaveChatSettings creates or updates the settings of a group or channel.
+func SaveChatSettings(settings *types.ChatSettings) error {
+	return instance.Save(settings).Error
+}
@@ -2,6 +2,9 @@ package database
 
 import (
 	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/types"
+	"context"
+	"errors"
 	"path/filepath"
 
 	"go.uber.org/zap"
@@ -48,7 +51,62 @@ func NewDatabase(log *zap.Logger) *DB {
 	}
 }
 
+// models contiene todas las tablas que se migran al iniciar
+var models = []interface{}{
+	&types.Stats{},
+	&types.UserFile{},
+	&types.Playlist{},
+	&types.ChatSettings{},
+	&types.UserSettings{},
+	&types.BannedUser{},
+	&types.AllowedUser{},
+	&types.AuditLog{},
+	&types.UserStats{},
+	&types.UserLimits{},
+	&types.User{},
+	&types.FileTraffic{},
+	&types.TrafficViewer{},
+	&types.TrafficSource{},
+}
+
+// InitDatabase abre la conexión, migra todos los modelos y carga las listas de acceso
+func InitDatabase(log *zap.Logger) error {
+	db := NewDatabase(log)
+	// une los álbumes partidos en varias playlists antes de crear su índice único
+	if err := mergeSplitAlbums(); err != nil {
+		return err
+	}
+	if err := db.Conn.AutoMigrate(models...); err != nil {
+		return err
+	}
+	return loadAccessLists()
+}
+
 // GetDB es el Getter público para obtener la instancia de GORM
 func GetDB() *gorm.DB {
 	return instance
 }
+
+// Ping comprueba que la base de datos responde
+func Ping(ctx context.Context) error {
+	if instance == nil {
+		return errors.New("database not initialized")
+	}
+	sqlDB, err := instance.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Close cierra la conexión con la base de datos
+func Close() error {
+	if instance == nil {
+		return nil
+	}
+	sqlDB, err := instance.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
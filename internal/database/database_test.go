@@ -0,0 +1,43 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// useTestDB points the package at a new in-memory database with every model
+// migrated, and empty ban and allow lists, for the length of the test.
+func useTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: is a database of its own
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	previous := instance
+	instance = db
+	resetAccessLists()
+	t.Cleanup(func() {
+		instance = previous
+		resetAccessLists()
+		sqlDB.Close()
+	})
+	return db
+}
+
+func resetAccessLists() {
+	access.Lock()
+	defer access.Unlock()
+	access.banned = make(map[int64]bool)
+	access.allowed = make(map[int64]bool)
+}
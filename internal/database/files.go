@@ -0,0 +1,167 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AddUserFile stores a processed file in the per-user file registry.
+func AddUserFile(file *types.UserFile) error {
+	return instance.Create(file).Error
+}
+
+// GetUserFile returns the registry entry for a log channel message ID.
+func GetUserFile(messageID int) (*types.UserFile, error) {
+	var file types.UserFile
+	err := instance.Where("message_id = ?", messageID).First(&file).Error
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// RevokeUserFile marks a file so its links stop working.
+func RevokeUserFile(messageID int) error {
+	return instance.Model(&types.UserFile{}).Where("message_id = ?", messageID).Update("revoked", true).Error
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, with \ as the escape
+// character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// SearchUserFiles returns the user's library files whose name or title
+// contains query, newest first. Revoked, expired and hidden files are left
+// out.
+func SearchUserFiles(ownerID int64, query string, offset int, limit int) ([]types.UserFile, error) {
+	var files []types.UserFile
+	tx := instance.Where("owner_id = ? AND revoked = ? AND hidden = ?", ownerID, false, false).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now())
+	if query != "" {
+		like := "%" + likeEscaper.Replace(query) + "%"
+		tx = tx.Where(`file_name LIKE ? ESCAPE '\' OR title LIKE ? ESCAPE '\'`, like, like)
+	}
+	err := tx.Order("message_id DESC").Offset(offset).Limit(limit).Find(&files).Error
+	return files, err
+}
+
+// GetPlaylist returns a playlist by its ID.
+func GetPlaylist(id string) (*types.Playlist, error) {
+	var playlist types.Playlist
+	err := instance.Where("id = ?", id).First(&playlist).Error
+	if err != nil {
+		return nil, err
+	}
+	return &playlist, nil
+}
+
+// GetPlaylistFiles returns the files of a playlist in the order they were sent.
+func GetPlaylistFiles(id string) ([]types.UserFile, error) {
+	var files []types.UserFile
+	err := instance.Where("playlist_id = ?", id).Order("message_id ASC").Find(&files).Error
+	return files, err
+}
+
+// GetAlbumPlaylist returns the playlist of an album, creating it when the
+// first file of the album arrives.
+func GetAlbumPlaylist(ownerID int64, groupedID int64) (*types.Playlist, error) {
+	var playlist types.Playlist
+	err := instance.Where("owner_id = ? AND grouped_id = ?", ownerID, groupedID).First(&playlist).Error
+	if err == nil {
+		return &playlist, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	// the parts of an album arrive at once, and the unique index keeps the
+	// playlist of whichever is created first
+	created := types.Playlist{ID: newPlaylistID(), OwnerID: ownerID, GroupedID: groupedID, Title: "Album"}
+	if err := instance.Clauses(clause.OnConflict{DoNothing: true}).Create(&created).Error; err != nil {
+		return nil, err
+	}
+	err = instance.Where("owner_id = ? AND grouped_id = ?", ownerID, groupedID).First(&playlist).Error
+	if err != nil {
+		return nil, err
+	}
+	return &playlist, nil
+}
+
+// mergeSplitAlbums joins the playlists albums were split into before
+// playlists had a unique index, so the index can be created. The oldest
+// playlist of each album is kept.
+func mergeSplitAlbums() error {
+	if !instance.Migrator().HasTable(&types.Playlist{}) {
+		return nil
+	}
+	return instance.Transaction(func(tx *gorm.DB) error {
+		var extra []types.Playlist
+		err := tx.Raw(`SELECT p.* FROM playlists p WHERE p.grouped_id <> 0 AND EXISTS (
+			SELECT 1 FROM playlists o WHERE o.owner_id = p.owner_id AND o.grouped_id = p.grouped_id
+			AND (o.created_at < p.created_at OR (o.created_at = p.created_at AND o.id < p.id)))`).
+			Scan(&extra).Error
+		if err != nil {
+			return err
+		}
+		for _, playlist := range extra {
+			var kept types.Playlist
+			err := tx.Where("owner_id = ? AND grouped_id = ?", playlist.OwnerID, playlist.GroupedID).
+				Order("created_at ASC, id ASC").
+				First(&kept).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Model(&types.UserFile{}).Where("playlist_id = ?", playlist.ID).Update("playlist_id", kept.ID).Error
+			if err != nil {
+				return err
+			}
+			if err := tx.Delete(&playlist).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// OpenBundle starts a new bundle for the user, closing any bundle left open.
+func OpenBundle(ownerID int64, title string) (*types.Playlist, error) {
+	if _, err := CloseBundle(ownerID); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	playlist := types.Playlist{ID: newPlaylistID(), OwnerID: ownerID, Title: title, Open: true}
+	if err := instance.Create(&playlist).Error; err != nil {
+		return nil, err
+	}
+	return &playlist, nil
+}
+
+// GetOpenBundle returns the bundle the user is currently collecting files into.
+func GetOpenBundle(ownerID int64) (*types.Playlist, error) {
+	var playlist types.Playlist
+	err := instance.Where("owner_id = ? AND open = ?", ownerID, true).First(&playlist).Error
+	if err != nil {
+		return nil, err
+	}
+	return &playlist, nil
+}
+
+// CloseBundle stops collecting files into the user's open bundle and returns it.
+func CloseBundle(ownerID int64) (*types.Playlist, error) {
+	playlist, err := GetOpenBundle(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	playlist.Open = false
+	return playlist, instance.Model(playlist).Update("open", false).Error
+}
+
+func newPlaylistID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
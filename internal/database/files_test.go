@@ -0,0 +1,55 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSearchUserFiles(t *testing.T) {
+	useTestDB(t)
+	expired := time.Now().Add(-time.Hour)
+	for i, file := range []types.UserFile{
+		{FileName: "holiday_2024.mp4"},
+		{FileName: "holiday 2024.mp4"},
+		{FileName: "100% done.mkv"},
+		{FileName: `C:\videos\clip.mp4`},
+		{FileName: "track.mp3", Title: "Holiday Song"},
+		{FileName: "holiday revoked.mp4", Revoked: true},
+		{FileName: "holiday expired.mp4", ExpiresAt: &expired},
+		{FileName: "holiday hidden.mp4", Hidden: true},
+	} {
+		file.OwnerID = 1
+		file.MessageID = i + 1
+		file.Hash = "hash"
+		if err := AddUserFile(&file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := AddUserFile(&types.UserFile{OwnerID: 2, MessageID: 100, Hash: "hash", FileName: "holiday other.mp4"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for query, want := range map[string][]string{
+		"holiday": {"track.mp3", "holiday 2024.mp4", "holiday_2024.mp4"},
+		"_":       {"holiday_2024.mp4"},
+		"%":       {"100% done.mkv"},
+		"y_2":     {"holiday_2024.mp4"},
+		`\`:       {`C:\videos\clip.mp4`},
+		"":        {"track.mp3", `C:\videos\clip.mp4`, "100% done.mkv", "holiday 2024.mp4", "holiday_2024.mp4"},
+		"missing": nil,
+	} {
+		files, err := SearchUserFiles(1, query, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, file := range files {
+			got = append(got, file.FileName)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("SearchUserFiles(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/types"
+	"errors"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReserveUserFile adds a file to the usage of a user on day, as given by
+// cache.StatsToday, if it fits in filesPerDay and bytesPerDay, zero meaning
+// unlimited. It reports whether the file was added. The check and the
+// update are a single statement, so files sent at once can't all pass the
+// check before any of them is counted.
+func ReserveUserFile(userID int64, day time.Time, fileSize, filesPerDay, bytesPerDay int64) (bool, error) {
+	err := instance.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "date"}},
+		DoNothing: true,
+	}).Create(&types.UserStats{UserID: userID, Date: day}).Error
+	if err != nil {
+		return false, err
+	}
+	res := instance.Model(&types.UserStats{}).
+		Where("user_id = ? AND date = ?", userID, day).
+		Where("(? = 0 OR file_count < ?)", filesPerDay, filesPerDay).
+		Where("(? = 0 OR total_size + ? <= ?)", bytesPerDay, fileSize, bytesPerDay).
+		Updates(map[string]interface{}{
+			"file_count": gorm.Expr("file_count + ?", 1),
+			"total_size": gorm.Expr("total_size + ?", fileSize),
+		})
+	return res.RowsAffected == 1, res.Error
+}
+
+// ReleaseUserFile takes back a file added by ReserveUserFile that could not
+// be processed after all.
+func ReleaseUserFile(userID int64, day time.Time, fileSize int64) error {
+	return instance.Model(&types.UserStats{}).
+		Where("user_id = ? AND date = ? AND file_count > 0", userID, day).
+		Updates(map[string]interface{}{
+			"file_count": gorm.Expr("file_count - ?", 1),
+			"total_size": gorm.Expr("MAX(total_size - ?, 0)", fileSize),
+		}).Error
+}
+
+// GetUserDayStats returns the usage of a user on day, as given by
+// cache.StatsToday.
+func GetUserDayStats(userID int64, day time.Time) (types.UserStats, error) {
+	stats := types.UserStats{UserID: userID, Date: day}
+	err := instance.Where("user_id = ? AND date = ?", userID, day).First(&stats).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return stats, nil
+	}
+	return stats, err
+}
+
+// GetUserLimits returns the quota overrides of a user.
+func GetUserLimits(userID int64) (*types.UserLimits, error) {
+	var limits types.UserLimits
+	err := instance.Where("user_id = ?", userID).First(&limits).Error
+	if err != nil {
+		return nil, err
+	}
+	return &limits, nil
+}
+
+// SaveUserLimits creates or updates the quota overrides of a user.
+func SaveUserLimits(limits *types.UserLimits) error {
+	return instance.Save(limits).Error
+}
+
+// DefaultQuota returns the quota from the config.
+func DefaultQuota() types.Quota {
+	return types.Quota{
+		FilesPerDay: config.ValueOf.FilesPerDay,
+		BytesPerDay: config.ValueOf.BytesPerDay,
+		MaxFileSize: config.ValueOf.MaxFileSize,
+		MaxStreams:  config.ValueOf.MaxStreams,
+	}
+}
+
+// GetUserQuota returns the quota of a user: the default one with the
+// user's overrides applied. Admins have no limits.
+func GetUserQuota(userID int64) types.Quota {
+	if slices.Contains(config.ValueOf.AdminIDs, userID) {
+		return types.Quota{}
+	}
+	quota := DefaultQuota()
+	limits, err := GetUserLimits(userID)
+	if err != nil {
+		return quota
+	}
+	for _, override := range []struct {
+		value  *int64
+		target *int64
+	}{
+		{limits.FilesPerDay, &quota.FilesPerDay},
+		{limits.BytesPerDay, &quota.BytesPerDay},
+		{limits.MaxFileSize, &quota.MaxFileSize},
+		{limits.MaxStreams, &quota.MaxStreams},
+	} {
+		if override.value != nil {
+			*override.target = *override.value
+		}
+	}
+	return quota
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReserveUserFile(t *testing.T) {
+	useTestDB(t)
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	reserve := func(size int64) bool {
+		t.Helper()
+		ok, err := ReserveUserFile(1, day, size, 3, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+	usage := func() (int64, int64) {
+		t.Helper()
+		stats, err := GetUserDayStats(1, day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stats.FileCount, stats.TotalSize
+	}
+
+	if !reserve(600) {
+		t.Fatal("first file refused")
+	}
+	if reserve(500) {
+		t.Error("file over the bytes per day accepted")
+	}
+	if !reserve(400) || !reserve(0) {
+		t.Fatal("files within the quota refused")
+	}
+	if reserve(0) {
+		t.Error("file over the files per day accepted")
+	}
+	if files, size := usage(); files != 3 || size != 1000 {
+		t.Errorf("usage = %d files, %d bytes, want 3 files, 1000 bytes", files, size)
+	}
+
+	if err := ReleaseUserFile(1, day, 400); err != nil {
+		t.Fatal(err)
+	}
+	if files, size := usage(); files != 2 || size != 600 {
+		t.Errorf("usage after release = %d files, %d bytes, want 2 files, 600 bytes", files, size)
+	}
+	if !reserve(400) {
+		t.Error("released room not reusable")
+	}
+
+	// other days and users have their own usage
+	if ok, err := ReserveUserFile(1, day.AddDate(0, 0, 1), 1000, 3, 1000); err != nil || !ok {
+		t.Errorf("next day = %v, %v, want true", ok, err)
+	}
+	if ok, err := ReserveUserFile(2, day, 1000, 3, 1000); err != nil || !ok {
+		t.Errorf("other user = %v, %v, want true", ok, err)
+	}
+}
+
+func TestReserveUserFileUnlimited(t *testing.T) {
+	useTestDB(t)
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		if ok, err := ReserveUserFile(1, day, 1<<40, 0, 0); err != nil || !ok {
+			t.Fatalf("file %d = %v, %v, want true", i, ok, err)
+		}
+	}
+	stats, err := GetUserDayStats(1, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.FileCount != 5 || stats.TotalSize != 5<<40 {
+		t.Errorf("usage = %d files, %d bytes, want 5 files, %d bytes", stats.FileCount, stats.TotalSize, int64(5<<40))
+	}
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"EverythingSuckz/fsb/internal/types"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// GetUserSettings returns the preferences of a user.
+func GetUserSettings(userID int64) (*types.UserSettings, error) {
+	var settings types.UserSettings
+	err := instance.Where("user_id = ?", userID).First(&settings).Error
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// SaveUserSettings creates or updates the preferences of a user.
+func SaveUserSettings(settings *types.UserSettings) error {
+	return instance.Save(settings).Error
+}
+
+// TouchUser records that a user interacted with the bot, making them active
+// again if they had blocked it.
+func TouchUser(user *types.User) error {
+	user.LastSeen = time.Now()
+	user.Inactive = false
+	return instance.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"username", "first_name", "inactive", "last_seen"}),
+	}).Create(user).Error
+}
+
+// GetActiveUserIDs returns the users who haven't blocked the bot.
+func GetActiveUserIDs() ([]int64, error) {
+	var ids []int64
+	err := instance.Model(&types.User{}).Where("inactive = ?", false).Order("user_id").Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// MarkUserInactive flags a user who blocked the bot or deleted their
+// account, so broadcasts skip them.
+func MarkUserInactive(userID int64) error {
+	return instance.Model(&types.User{}).Where("user_id = ?", userID).Update("inactive", true).Error
+}
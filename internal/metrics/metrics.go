@@ -0,0 +1,255 @@
+// Package metrics keeps the counters served at /metrics, written in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DurationBuckets are the upper bounds, in seconds, of the latency
+// histograms.
+var DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+var (
+	HTTPRequests = NewCounterVec("fsb_http_requests_total",
+		"HTTP requests by route, method and status code.", "route", "method", "status")
+	HTTPDuration = NewHistogramVec("fsb_http_request_duration_seconds",
+		"Time to serve HTTP requests, streams included.", DurationBuckets, "route", "method")
+	ActiveStreams = NewGaugeVec("fsb_active_streams",
+		"Streams being served right now.")
+	BytesServed = NewCounterVec("fsb_bytes_served_total",
+		"Bytes of files sent to clients.")
+	GetFileCalls = NewCounterVec("fsb_upload_get_file_total",
+		"upload.getFile calls by bot.", "worker")
+	GetFileErrors = NewCounterVec("fsb_upload_get_file_errors_total",
+		"upload.getFile calls that failed, by bot.", "worker")
+	GetFileDuration = NewHistogramVec("fsb_upload_get_file_duration_seconds",
+		"Latency of upload.getFile calls by bot.", DurationBuckets, "worker")
+	FloodWaits = NewCounterVec("fsb_flood_waits_total",
+		"FLOOD_WAIT errors returned by Telegram, by bot.", "worker")
+	FloodWaitSeconds = NewCounterVec("fsb_flood_wait_seconds_total",
+		"Seconds Telegram asked to wait in FLOOD_WAIT errors, by bot.", "worker")
+)
+
+type metric interface {
+	write(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []metric
+)
+
+func register(m metric) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, m)
+}
+
+// Write writes every metric in the Prometheus text format.
+func Write(w io.Writer) {
+	registryMu.Lock()
+	metrics := append([]metric(nil), registry...)
+	registryMu.Unlock()
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// vec holds one value per combination of label values.
+type vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newVec(kind, name, help string, labels []string) *vec {
+	v := &vec{name: name, help: help, kind: kind, labels: labels, values: make(map[string]float64)}
+	register(v)
+	return v
+}
+
+func (v *vec) add(delta float64, labelValues []string) {
+	key := seriesKey(v.labels, labelValues)
+	v.mu.Lock()
+	v.values[key] += delta
+	v.mu.Unlock()
+}
+
+func (v *vec) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.kind)
+	if len(v.labels) == 0 && len(v.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", v.name)
+		return
+	}
+	for _, key := range sortedKeys(v.values) {
+		fmt.Fprintf(w, "%s%s %s\n", v.name, labelPairs(v.labels, key, ""), formatFloat(v.values[key]))
+	}
+}
+
+// CounterVec is a counter with labels. Without labels, it's a plain counter.
+type CounterVec struct{ *vec }
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{newVec("counter", name, help, labels)}
+}
+
+// Inc adds 1 to the series of the given label values.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.add(1, labelValues)
+}
+
+// Add adds a positive delta to the series of the given label values.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta > 0 {
+		c.add(delta, labelValues)
+	}
+}
+
+// GaugeVec is a value that goes up and down, with labels.
+type GaugeVec struct{ *vec }
+
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{newVec("gauge", name, help, labels)}
+}
+
+// Add adds delta, which may be negative, to the series of the given label
+// values.
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	g.add(delta, labelValues)
+}
+
+// HistogramVec counts observations in buckets, with labels.
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*histogram)}
+	register(h)
+	return h
+}
+
+// Observe records a value in the series of the given label values.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := seriesKey(h.labels, labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += value
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			le := `le="` + formatFloat(bound) + `"`
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelPairs(h.labels, key, le), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelPairs(h.labels, key, `le="+Inf"`), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labelPairs(h.labels, key, ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labelPairs(h.labels, key, ""), s.count)
+	}
+}
+
+// valueFunc is a metric read when /metrics is scraped, for values kept
+// elsewhere such as the cache counters.
+type valueFunc struct {
+	name string
+	help string
+	kind string
+	fn   func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is returned by fn.
+func NewGaugeFunc(name, help string, fn func() float64) {
+	register(&valueFunc{name: name, help: help, kind: "gauge", fn: fn})
+}
+
+// NewCounterFunc registers a counter whose value is returned by fn.
+func NewCounterFunc(name, help string, fn func() float64) {
+	register(&valueFunc{name: name, help: help, kind: "counter", fn: fn})
+}
+
+func (f *valueFunc) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", f.name, f.help, f.name, f.kind, f.name, formatFloat(f.fn()))
+}
+
+// seriesKey joins label values, padding or cutting them to the number of
+// labels.
+func seriesKey(labels []string, values []string) string {
+	key := make([]string, len(labels))
+	copy(key, values)
+	return strings.Join(key, "\xff")
+}
+
+func labelPairs(labels []string, key string, extra string) string {
+	var pairs []string
+	if len(labels) != 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, labels[i]+`="`+escapeLabel(value)+`"`)
+		}
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// accessLog logs every request once it's served, with the client IP given
+// by the trusted proxies.
+func accessLog(log *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		fields := []zap.Field{
+			zap.String("ip", c.ClientIP()),
+			zap.String("method", c.Request.Method),
+			zap.String("route", route),
+			zap.Int("status", c.Writer.Status()),
+			zap.Int("bytes", max(c.Writer.Size(), 0)),
+			zap.Duration("duration", time.Since(start)),
+		}
+		if messageID := c.Param("messageID"); messageID != "" {
+			fields = append(fields, zap.String("message_id", messageID))
+		}
+		if rangeHeader := c.GetHeader("Range"); rangeHeader != "" {
+			fields = append(fields, zap.String("range", rangeHeader))
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, zap.String("error", c.Errors.String()))
+		}
+		log.Info("Request", fields...)
+	}
+}
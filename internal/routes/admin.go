@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/bot"
+	"net/http"
+	"net/http/pprof"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type adminRoutes struct {
+	log *zap.Logger
+}
+
+// LoadAdmin adds the routes served on ADMIN_LISTEN, which must not be
+// reachable from the internet: the metrics, the admin API and pprof.
+func LoadAdmin(log *zap.Logger, r *gin.Engine) {
+	log = log.Named("admin_routes")
+	defer log.Sugar().Info("Loaded all admin routes")
+	route := &Route{Name: "/", Engine: r}
+	route.Init(r)
+	Type := reflect.TypeOf(&adminRoutes{log})
+	Value := reflect.ValueOf(&adminRoutes{log})
+	for i := 0; i < Type.NumMethod(); i++ {
+		Type.Method(i).Func.Call([]reflect.Value{Value, reflect.ValueOf(route)})
+	}
+}
+
+func (a *adminRoutes) LoadMetrics(route *Route) {
+	route.Engine.GET("/metrics", getMetrics)
+}
+
+func (a *adminRoutes) LoadAdminAPI(route *Route) {
+	admin := route.Engine.Group("/admin")
+	admin.GET("/workers", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": bot.WorkersInfo()})
+	})
+	admin.GET("/config", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": config.ValueOf.Redacted()})
+	})
+}
+
+func (a *adminRoutes) LoadPprof(route *Route) {
+	debug := route.Engine.Group("/debug/pprof")
+	debug.GET("/", gin.WrapF(pprof.Index))
+	debug.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+	debug.GET("/profile", gin.WrapF(pprof.Profile))
+	debug.GET("/symbol", gin.WrapF(pprof.Symbol))
+	debug.POST("/symbol", gin.WrapF(pprof.Symbol))
+	debug.GET("/trace", gin.WrapF(pprof.Trace))
+	// heap, goroutine, allocs and the other runtime profiles
+	debug.GET("/:profile", gin.WrapF(pprof.Index))
+}
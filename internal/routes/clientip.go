@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CloudflareHeader removes the CF-Connecting-IP header from requests that
+// don't come from one of the Cloudflare ranges, so a client can't pick its
+// own IP through another of TRUSTED_PROXIES, such as nginx, that passes the
+// header on. It must run before anything reads the client IP.
+func CloudflareHeader(ranges []string) (gin.HandlerFunc, error) {
+	nets, err := parseNets(ranges)
+	if err != nil {
+		return nil, err
+	}
+	return func(c *gin.Context) {
+		if c.GetHeader("CF-Connecting-IP") != "" && !inNets(nets, net.ParseIP(c.RemoteIP())) {
+			c.Request.Header.Del("CF-Connecting-IP")
+		}
+		c.Next()
+	}, nil
+}
+
+// parseNets parses CIDR ranges and single IPs.
+func parseNets(ranges []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(ranges))
+	for _, r := range ranges {
+		if !strings.Contains(r, "/") {
+			if ip := net.ParseIP(r); ip != nil && ip.To4() != nil {
+				r += "/32"
+			} else {
+				r += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP range %q: %w", r, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// inNets reports whether ip is in one of nets.
+func inNets(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsExposedHeaders are the response headers scripts of other origins
+// need to play files with range requests.
+const corsExposedHeaders = "Content-Range, Accept-Ranges, Content-Length, Content-Disposition"
+
+// cors lets the web apps of CORS_ORIGINS read the responses, and answers
+// their preflight requests.
+func cors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(config.ValueOf.CORSOrigins) == 0 {
+			c.Next()
+			return
+		}
+		header := c.Writer.Header()
+		allowOrigin, ok := corsOrigin(c.GetHeader("Origin"))
+		if allowOrigin != "*" {
+			header.Add("Vary", "Origin")
+		}
+		if !ok {
+			c.Next()
+			return
+		}
+		header.Set("Access-Control-Allow-Origin", allowOrigin)
+		header.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			header.Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+			header.Set("Access-Control-Allow-Headers", "Range")
+			header.Set("Access-Control-Max-Age", "86400")
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
+
+// corsOrigin returns the Access-Control-Allow-Origin of a request from
+// origin, and false if it isn't one of CORS_ORIGINS.
+func corsOrigin(origin string) (string, bool) {
+	for _, allowed := range config.ValueOf.CORSOrigins {
+		if allowed == "*" {
+			return "*", true
+		}
+		if origin != "" && strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// hotlinkProtection refuses requests referred by sites other than HOST,
+// CORS_ORIGINS and ALLOWED_REFERERS. Requests without a Referer, such as
+// those of players and download managers, are let through.
+func hotlinkProtection() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(config.ValueOf.AllowedReferers) == 0 || c.Request.Referer() == "" {
+			c.Next()
+			return
+		}
+		referer, err := url.Parse(c.Request.Referer())
+		if err != nil || !refererAllowed(strings.ToLower(referer.Hostname())) {
+			http.Error(c.Writer, "embedding this file on other sites is not allowed", http.StatusForbidden)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// refererAllowed reports whether host is the host of HOST or of one of
+// CORS_ORIGINS, or matches one of ALLOWED_REFERERS, where "*.example.com"
+// matches its subdomains.
+func refererAllowed(host string) bool {
+	for _, origin := range append([]string{config.ValueOf.Host}, config.ValueOf.CORSOrigins...) {
+		if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Hostname(), host) {
+			return true
+		}
+	}
+	for _, allowed := range config.ValueOf.AllowedReferers {
+		allowed = strings.ToLower(allowed)
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
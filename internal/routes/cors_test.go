@@ -0,0 +1,64 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"testing"
+)
+
+func TestCorsOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    string
+		wantOK  bool
+	}{
+		{name: "listed", allowed: []string{"https://app.example.com"}, origin: "https://app.example.com", want: "https://app.example.com", wantOK: true},
+		{name: "listed with slash", allowed: []string{"https://app.example.com/"}, origin: "https://app.example.com", want: "https://app.example.com", wantOK: true},
+		{name: "other case", allowed: []string{"https://App.Example.com"}, origin: "https://app.example.com", want: "https://app.example.com", wantOK: true},
+		{name: "second of several", allowed: []string{"https://a.example.com", "https://b.example.com"}, origin: "https://b.example.com", want: "https://b.example.com", wantOK: true},
+		{name: "wildcard", allowed: []string{"*"}, origin: "https://evil.example", want: "*", wantOK: true},
+		{name: "wildcard without origin", allowed: []string{"*"}, origin: "", want: "*", wantOK: true},
+		{name: "not listed", allowed: []string{"https://app.example.com"}, origin: "https://evil.example"},
+		{name: "other scheme", allowed: []string{"https://app.example.com"}, origin: "http://app.example.com"},
+		{name: "subdomain", allowed: []string{"https://example.com"}, origin: "https://app.example.com"},
+		{name: "no origin", allowed: []string{"https://app.example.com"}, origin: ""},
+		{name: "none allowed", origin: "https://app.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.ValueOf.CORSOrigins = tt.allowed
+			got, ok := corsOrigin(tt.origin)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("corsOrigin(%q) = %q, %v, want %q, %v", tt.origin, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRefererAllowed(t *testing.T) {
+	config.ValueOf.Host = "https://files.example.com"
+	config.ValueOf.CORSOrigins = []string{"https://app.example.org"}
+	config.ValueOf.AllowedReferers = []string{"blog.example.net", "*.Example.io"}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{host: "files.example.com", want: true},
+		{host: "app.example.org", want: true},
+		{host: "blog.example.net", want: true},
+		{host: "www.example.io", want: true},
+		{host: "a.b.example.io", want: true},
+		// the wildcard only matches subdomains
+		{host: "example.io", want: false},
+		{host: "badexample.io", want: false},
+		{host: "example.com", want: false},
+		{host: "www.blog.example.net", want: false},
+		{host: "evil.example", want: false},
+	}
+	for _, tt := range tests {
+		if got := refererAllowed(tt.host); got != tt.want {
+			t.Errorf("refererAllowed(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
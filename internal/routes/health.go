@@ -0,0 +1,101 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/bot"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout bounds the checks of /readyz, so a stuck connection
+// fails the probe instead of hanging it.
+const readinessTimeout = 5 * time.Second
+
+func (r *allRoutes) LoadHealth(route *Route) {
+	route.Engine.GET("/healthz", getHealth)
+	route.Engine.HEAD("/healthz", getHealth)
+	route.Engine.GET("/readyz", getReadiness)
+	route.Engine.HEAD("/readyz", getReadiness)
+}
+
+// getHealth reports that the process is alive and serving HTTP.
+func getHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// getReadiness checks that files can be served: the main bot is connected,
+// at least one worker answers, the database responds and the log channel
+// resolves. It answers 503 if any check fails.
+func getReadiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, readinessTimeout)
+	defer cancel()
+
+	checks := map[string]func(ctx context.Context) types.HealthCheck{
+		"bot": func(ctx context.Context) types.HealthCheck {
+			if bot.Bot == nil {
+				return healthCheck(errors.New("not started"))
+			}
+			return healthCheck(bot.Bot.Ping(ctx))
+		},
+		"workers": func(ctx context.Context) types.HealthCheck {
+			healthy, total := bot.PingWorkers(ctx)
+			var err error
+			if healthy == 0 {
+				err = errors.New("no worker answered")
+			}
+			check := healthCheck(err)
+			check.Healthy, check.Total = healthy, total
+			return check
+		},
+		"database": func(ctx context.Context) types.HealthCheck {
+			return healthCheck(database.Ping(ctx))
+		},
+		"log_channel": func(ctx context.Context) types.HealthCheck {
+			if bot.Bot == nil {
+				return healthCheck(errors.New("bot not started"))
+			}
+			_, err := utils.GetLogChannelPeer(ctx, bot.Bot.API(), bot.Bot.PeerStorage)
+			return healthCheck(err)
+		},
+	}
+
+	response := types.ReadinessResponse{Ready: true, Checks: make(map[string]types.HealthCheck, len(checks))}
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) types.HealthCheck) {
+			defer wg.Done()
+			start := time.Now()
+			result := check(ctx)
+			result.Latency = time.Since(start).Round(time.Millisecond).String()
+			mu.Lock()
+			defer mu.Unlock()
+			response.Checks[name] = result
+			response.Ready = response.Ready && result.Ok
+		}(name, check)
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	if !response.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, response)
+}
+
+func healthCheck(err error) types.HealthCheck {
+	if err != nil {
+		return types.HealthCheck{Ok: false, Error: err.Error()}
+	}
+	return types.HealthCheck{Ok: true}
+}
@@ -0,0 +1,51 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"EverythingSuckz/fsb/internal/cache"
+	"EverythingSuckz/fsb/internal/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (r *allRoutes) LoadMetrics(route *Route) {
+	// the file cache keeps its own counters, read on every scrape
+	metrics.NewCounterFunc("fsb_file_cache_hits_total", "File properties cache hits.", func() float64 {
+		return float64(cache.GetCache().Stats().Hits)
+	})
+	metrics.NewCounterFunc("fsb_file_cache_misses_total", "File properties cache misses.", func() float64 {
+		return float64(cache.GetCache().Stats().Misses)
+	})
+	metrics.NewGaugeFunc("fsb_file_cache_hit_ratio", "Ratio of file properties cache lookups that were hits.", func() float64 {
+		return cache.GetCache().Stats().HitRate
+	})
+	metrics.NewGaugeFunc("fsb_file_cache_entries", "Entries in the file properties cache.", func() float64 {
+		return float64(cache.GetCache().Stats().Entries)
+	})
+	// /metrics is served on ADMIN_LISTEN, and publicly only when asked to
+	if config.ValueOf.PublicMetrics {
+		route.Engine.GET("/metrics", getMetrics)
+	}
+}
+
+// getMetrics serves the metrics in the Prometheus text format.
+func getMetrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.Write(c.Writer)
+}
+
+// httpMetrics counts the requests and their latency per route.
+func httpMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequests.Inc(route, c.Request.Method, strconv.Itoa(c.Writer.Status()))
+		metrics.HTTPDuration.Observe(time.Since(start).Seconds(), route, c.Request.Method)
+	}
+}
@@ -0,0 +1,119 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type xspfPlaylist struct {
+	XMLName   xml.Name    `xml:"playlist"`
+	Version   string      `xml:"version,attr"`
+	Namespace string      `xml:"xmlns,attr"`
+	Title     string      `xml:"title"`
+	Tracks    []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location string `xml:"location"`
+	Title    string `xml:"title"`
+	Duration int64  `xml:"duration,omitempty"` // in milliseconds
+}
+
+func (r *allRoutes) LoadPlaylist(route *Route) {
+	route.Engine.GET("/playlist/:id", r.getPlaylist)
+}
+
+func (r *allRoutes) getPlaylist(c *gin.Context) {
+	param := c.Param("id")
+	format := strings.TrimPrefix(path.Ext(param), ".")
+	id := strings.TrimSuffix(param, path.Ext(param))
+	if format != "m3u8" && format != "xspf" {
+		http.Error(c.Writer, "unsupported playlist format", http.StatusBadRequest)
+		return
+	}
+	viewerID, ok := checkViewerToken(c.Writer, c.Query("token"), utils.PlaylistScope(id))
+	if !ok {
+		return
+	}
+
+	playlist, err := database.GetPlaylist(id)
+	if err != nil {
+		http.Error(c.Writer, "playlist not found", http.StatusNotFound)
+		return
+	}
+	files, err := database.GetPlaylistFiles(playlist.ID)
+	if err != nil {
+		r.log.Error("Failed to get playlist files", zap.Error(err))
+		http.Error(c.Writer, "failed to retrieve playlist", http.StatusInternalServerError)
+		return
+	}
+	files = liveTracks(files)
+
+	if format == "xspf" {
+		out := xspfPlaylist{Version: "1", Namespace: "http://xspf.org/ns/0/", Title: playlist.Title}
+		for _, file := range files {
+			out.Tracks = append(out.Tracks, xspfTrack{
+				Location: trackLink(viewerID, &file),
+				Title:    trackTitle(&file),
+				Duration: int64(file.Duration) * 1000,
+			})
+		}
+		body, err := xml.MarshalIndent(out, "", "  ")
+		if err != nil {
+			http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s.xspf\"", playlist.ID))
+		c.Data(http.StatusOK, "application/xspf+xml", append([]byte(xml.Header), body...))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("#EXTM3U\n")
+	sb.WriteString("#PLAYLIST:" + playlist.Title + "\n")
+	for _, file := range files {
+		duration := file.Duration
+		if duration == 0 {
+			duration = -1
+		}
+		fmt.Fprintf(&sb, "#EXTINF:%d,%s\n", duration, trackTitle(&file))
+		sb.WriteString(trackLink(viewerID, &file) + "\n")
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s.m3u8\"", playlist.ID))
+	c.Data(http.StatusOK, "application/vnd.apple.mpegurl", []byte(sb.String()))
+}
+
+// liveTracks leaves out the files whose links would be refused, such as
+// revoked and expired ones.
+func liveTracks(files []types.UserFile) []types.UserFile {
+	live := files[:0]
+	for _, file := range files {
+		if _, err := database.CheckLink(file.MessageID); err == nil {
+			live = append(live, file)
+		}
+	}
+	return live
+}
+
+// trackLink returns the stream link of a track, with a viewer token for the
+// file issued to the holder of the playlist token.
+func trackLink(viewerID int64, file *types.UserFile) string {
+	token := utils.NewViewerToken(viewerID, utils.FileScope(file.MessageID))
+	return utils.WithViewerToken(utils.GetStreamLink(file.MessageID, file.Hash), token)
+}
+
+func trackTitle(file *types.UserFile) string {
+	if file.Title != "" {
+		return file.Title
+	}
+	return file.FileName
+}
@@ -0,0 +1,167 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/config"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// limiterIdleTimeout is how long the bucket of a client or link is kept
+// after its last request.
+const limiterIdleTimeout = 10 * time.Minute
+
+// stopLimiters is closed by Stop to end the cleanup of every limiterSet.
+var (
+	stopLimiters     = make(chan struct{})
+	stopLimitersOnce sync.Once
+)
+
+// Stop ends the background work of the routes, such as the cleanup of the
+// rate limit buckets. It's called on shutdown once the servers are stopped.
+func Stop() {
+	stopLimitersOnce.Do(func() { close(stopLimiters) })
+}
+
+// limiterSet holds one token bucket per key, such as a client IP.
+type limiterSet struct {
+	mu       sync.Mutex
+	limit    rate.Limit
+	burst    int
+	limiters map[string]*limiterEntry
+}
+
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newLimiterSet(perSecond float64, burst int) *limiterSet {
+	s := &limiterSet{
+		limit:    rate.Limit(perSecond),
+		burst:    max(burst, 1),
+		limiters: make(map[string]*limiterEntry),
+	}
+	go s.cleanup(stopLimiters)
+	return s
+}
+
+// reserve takes a token from the bucket of key, returning how long the
+// client has to wait if there is none left.
+func (s *limiterSet) reserve(key string) (time.Duration, bool) {
+	s.mu.Lock()
+	entry, ok := s.limiters[key]
+	if !ok {
+		entry = &limiterEntry{limiter: rate.NewLimiter(s.limit, s.burst)}
+		s.limiters[key] = entry
+	}
+	entry.lastSeen = time.Now()
+	s.mu.Unlock()
+
+	reservation := entry.limiter.Reserve()
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return delay, false
+	}
+	return 0, true
+}
+
+// cleanup drops the buckets idle for limiterIdleTimeout until stop is
+// closed.
+func (s *limiterSet) cleanup(stop <-chan struct{}) {
+	ticker := time.NewTicker(limiterIdleTimeout)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.removeIdle()
+		}
+	}
+}
+
+func (s *limiterSet) removeIdle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, entry := range s.limiters {
+		if time.Since(entry.lastSeen) > limiterIdleTimeout {
+			delete(s.limiters, key)
+		}
+	}
+}
+
+// rateLimit returns a middleware that allows perSecond requests with bursts
+// of burst for each key, answering 429 to the rest.
+func rateLimit(perSecond float64, burst int, key func(*gin.Context) string) gin.HandlerFunc {
+	if perSecond <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+	limiters := newLimiterSet(perSecond, burst)
+	return func(c *gin.Context) {
+		delay, ok := limiters.reserve(key(c))
+		if !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ipRateLimit limits the requests of each client IP following
+// IP_RATE_LIMIT and IP_RATE_BURST.
+func ipRateLimit() gin.HandlerFunc {
+	return rateLimit(config.ValueOf.IPRateLimit, config.ValueOf.IPRateBurst, func(c *gin.Context) string {
+		return c.ClientIP()
+	})
+}
+
+// linkRateLimit limits the requests to each file following LINK_RATE_LIMIT
+// and LINK_RATE_BURST, no matter who makes them.
+func linkRateLimit() gin.HandlerFunc {
+	return rateLimit(config.ValueOf.LinkRateLimit, config.ValueOf.LinkRateBurst, func(c *gin.Context) string {
+		return c.Param("messageID")
+	})
+}
+
+// throttledReader caps the speed at which a stream is read.
+type throttledReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *rate.Limiter
+}
+
+// newThrottledReader returns a reader that reads at most bytesPerSecond from
+// r, or r itself if bytesPerSecond is 0.
+func newThrottledReader(ctx context.Context, r io.Reader, bytesPerSecond int64) io.Reader {
+	if bytesPerSecond <= 0 {
+		return r
+	}
+	burst := int(min(bytesPerSecond, math.MaxInt32))
+	return &throttledReader{
+		ctx:     ctx,
+		reader:  r,
+		limiter: rate.NewLimiter(rate.Limit(bytesPerSecond), burst),
+	}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > t.limiter.Burst() {
+		p = p[:t.limiter.Burst()]
+	}
+	n, err := t.reader.Read(p)
+	if n > 0 {
+		if waitErr := t.limiter.WaitN(t.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
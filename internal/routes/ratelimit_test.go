@@ -0,0 +1,113 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestLimiterSet(t *testing.T) {
+	tests := []struct {
+		name      string
+		perSecond float64
+		burst     int
+		allowed   int // requests allowed in a row before the first refusal
+	}{
+		{name: "burst", perSecond: 1, burst: 3, allowed: 3},
+		{name: "no burst", perSecond: 1, burst: 1, allowed: 1},
+		{name: "zero burst allows one", perSecond: 1, burst: 0, allowed: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newLimiterSet(tt.perSecond, tt.burst)
+			for i := 0; i < tt.allowed; i++ {
+				if _, ok := s.reserve("a"); !ok {
+					t.Fatalf("request %d refused, want %d allowed", i+1, tt.allowed)
+				}
+			}
+			delay, ok := s.reserve("a")
+			if ok {
+				t.Fatalf("request %d allowed, want it refused", tt.allowed+1)
+			}
+			if delay <= 0 || delay > time.Second {
+				t.Errorf("delay = %s, want up to 1s", delay)
+			}
+			// other keys have their own bucket
+			if _, ok := s.reserve("b"); !ok {
+				t.Error("request of another key refused")
+			}
+		})
+	}
+}
+
+func TestLimiterSetRemoveIdle(t *testing.T) {
+	s := newLimiterSet(1, 1)
+	s.reserve("idle")
+	s.reserve("active")
+	s.limiters["idle"].lastSeen = time.Now().Add(-limiterIdleTimeout - time.Second)
+	s.removeIdle()
+	if _, ok := s.limiters["idle"]; ok {
+		t.Error("idle bucket kept")
+	}
+	if _, ok := s.limiters["active"]; !ok {
+		t.Error("active bucket removed")
+	}
+}
+
+func TestThrottledReader(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 300)
+	tests := []struct {
+		name           string
+		bytesPerSecond int64
+		maxRead        int           // largest single read
+		minTime        time.Duration // time to read data
+	}{
+		{name: "unlimited", bytesPerSecond: 0, maxRead: len(data)},
+		{name: "under the burst", bytesPerSecond: 1 << 20, maxRead: len(data)},
+		// the first 2000 bytes are the burst, the other 1000 take 500ms
+		{name: "limited", bytesPerSecond: 2000, maxRead: 2000, minTime: 400 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newThrottledReader(context.Background(), bytes.NewReader(data), tt.bytesPerSecond)
+			var got []byte
+			buf := make([]byte, len(data))
+			start := time.Now()
+			for {
+				n, err := r.Read(buf)
+				if n > tt.maxRead {
+					t.Fatalf("read %d bytes, want at most %d", n, tt.maxRead)
+				}
+				got = append(got, buf[:n]...)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if elapsed := time.Since(start); elapsed < tt.minTime {
+				t.Errorf("read in %s, want at least %s", elapsed, tt.minTime)
+			}
+			if !bytes.Equal(got, data) {
+				t.Error("data changed by the reader")
+			}
+		})
+	}
+}
+
+func TestThrottledReaderCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := newThrottledReader(ctx, bytes.NewReader(make([]byte, 100)), 10)
+	buf := make([]byte, 100)
+	if _, err := r.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := r.Read(buf); !errors.Is(err, context.Canceled) {
+		t.Errorf("Read() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -25,6 +25,7 @@ func Load(log *zap.Logger, r *gin.Engine) {
 	defer log.Sugar().Info("Loaded all API Routes")
 	route := &Route{Name: "/", Engine: r}
 	route.Init(r)
+	r.Use(accessLog(log.Named("access")), httpMetrics(), cors(), ipRateLimit())
 	Type := reflect.TypeOf(&allRoutes{log})
 	Value := reflect.ValueOf(&allRoutes{log})
 	for i := 0; i < Type.NumMethod(); i++ {
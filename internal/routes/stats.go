@@ -2,7 +2,14 @@ package routes
 
 import (
 	"EverythingSuckz/fsb/internal/cache"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/types"
+	"EverythingSuckz/fsb/internal/utils"
+	"encoding/csv"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -10,6 +17,8 @@ import (
 
 func (r *allRoutes) LoadStatsAPI(route *Route) {
 	route.Engine.GET("/api/stats", r.getStats)
+	route.Engine.GET("/api/stats/range", r.getRangeStats)
+	route.Engine.GET("/api/links/:messageID/stats", r.getLinkStats)
 }
 
 func (r *allRoutes) getStats(c *gin.Context) {
@@ -21,7 +30,7 @@ func (r *allRoutes) getStats(c *gin.Context) {
 		return
 	}
 
-	stats, err := statsCache.GetCompleteStats()
+	stats, err := statsCache.GetRecentStats()
 	if err != nil {
 		r.log.Error("Failed to get statistics", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -30,8 +39,122 @@ func (r *allRoutes) getStats(c *gin.Context) {
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    stats.Public(),
+	})
+}
+
+// getRangeStats returns the stats of each day, week or month of a range,
+// as JSON or, with format=csv, as CSV. from and to are dates in
+// STATS_TIMEZONE and default to the last 30 days.
+func (r *allRoutes) getRangeStats(c *gin.Context) {
+	statsCache := cache.GetStatsCache()
+	if statsCache == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Statistics service is not available",
+		})
+		return
+	}
+
+	to := cache.StatsToday()
+	if value := c.Query("to"); value != "" {
+		day, err := cache.ParseStatsDay(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date in YYYY-MM-DD format"})
+			return
+		}
+		to = day
+	}
+	from := to.AddDate(0, 0, -29)
+	if value := c.Query("from"); value != "" {
+		day, err := cache.ParseStatsDay(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date in YYYY-MM-DD format"})
+			return
+		}
+		from = day
+	}
+	granularity := c.DefaultQuery("granularity", types.GranularityDay)
+
+	periods, err := statsCache.GetRangeStats(from, to, granularity)
+	if errors.Is(err, cache.ErrInvalidRange) || errors.Is(err, cache.ErrInvalidGranularity) || errors.Is(err, cache.ErrTooManyPeriods) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		r.log.Error("Failed to get statistics", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to retrieve statistics",
+		})
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		writeStatsCSV(c, periods, fmt.Sprintf("stats-%s-%s.csv", from.Format("2006-01-02"), to.Format("2006-01-02")))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"timezone":    cache.StatsLocation().String(),
+		"granularity": granularity,
+		"data":        periods,
+	})
+}
+
+func writeStatsCSV(c *gin.Context, periods []types.PeriodStats, fileName string) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"start", "end", "file_count", "total_size", "bytes_served", "requests", "unique_viewers"})
+	for _, period := range periods {
+		_ = w.Write([]string{
+			period.Start,
+			period.End,
+			strconv.FormatInt(period.FileCount, 10),
+			strconv.FormatInt(period.TotalSize, 10),
+			strconv.FormatInt(period.Traffic.Bytes, 10),
+			strconv.FormatInt(period.Traffic.Requests, 10),
+			strconv.FormatInt(period.Traffic.UniqueViewers, 10),
+		})
+	}
+	w.Flush()
+}
+
+// getLinkStats returns the views of one file. The token is given to the
+// owner of the file by /linkstats.
+func (r *allRoutes) getLinkStats(c *gin.Context) {
+	messageID, err := strconv.Atoi(c.Param("messageID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message ID"})
+		return
+	}
+	file, err := database.GetUserFile(messageID)
+	if err != nil || !utils.CheckLinkStatsToken(c.Query("token"), messageID, file.OwnerID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid token"})
+		return
+	}
+	statsCache := cache.GetStatsCache()
+	if statsCache == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Statistics service is not available",
+		})
+		return
+	}
+
+	stats, err := statsCache.GetLinkStats(messageID)
+	if err != nil {
+		r.log.Error("Failed to get link statistics", zap.Error(err), zap.Int("messageID", messageID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to retrieve statistics",
+		})
+		return
+	}
+	stats.FileName = file.FileName
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    stats,
 	})
-} 
+}
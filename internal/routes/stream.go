@@ -1,12 +1,20 @@
 package routes
 
 import (
+	"EverythingSuckz/fsb/config"
 	"EverythingSuckz/fsb/internal/bot"
+	"EverythingSuckz/fsb/internal/cache"
+	"EverythingSuckz/fsb/internal/database"
+	"EverythingSuckz/fsb/internal/metrics"
 	"EverythingSuckz/fsb/internal/utils"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/gotd/td/tg"
 	range_parser "github.com/quantumsheep/range-parser"
@@ -20,7 +28,7 @@ var log *zap.Logger
 func (e *allRoutes) LoadHome(r *Route) {
 	log = e.log.Named("Stream")
 	defer log.Info("Loaded stream route")
-	r.Engine.GET("/stream/:messageID", getStreamRoute)
+	r.Engine.GET("/stream/:messageID", linkRateLimit(), hotlinkProtection(), getStreamRoute)
 }
 
 func getStreamRoute(ctx *gin.Context) {
@@ -58,6 +66,20 @@ func getStreamRoute(ctx *gin.Context) {
 		http.Error(w, "invalid hash", http.StatusBadRequest)
 		return
 	}
+	if _, ok := checkViewerToken(w, ctx.Query("token"), utils.FileScope(messageID)); !ok {
+		return
+	}
+
+	ownerID, ok := checkLinkOwner(w, messageID)
+	if !ok {
+		return
+	}
+	release, ok := acquireStream(ownerID)
+	if !ok {
+		http.Error(w, "too many concurrent streams of this user's files", http.StatusTooManyRequests)
+		return
+	}
+	defer release()
 
 	// for photo messages
 	if file.FileSize == 0 {
@@ -79,6 +101,8 @@ func getStreamRoute(ctx *gin.Context) {
 		ctx.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", file.FileName))
 		if r.Method != "HEAD" {
 			ctx.Data(http.StatusOK, file.MimeType, fileBytes)
+			metrics.BytesServed.Add(float64(len(fileBytes)))
+			recordTraffic(ctx, messageID, int64(len(fileBytes)))
 		}
 		return
 	}
@@ -124,8 +148,99 @@ func getStreamRoute(ctx *gin.Context) {
 
 	if r.Method != "HEAD" {
 		lr, _ := utils.NewTelegramReader(ctx, worker.Client, file.Location, start, end, contentLength)
-		if _, err := io.CopyN(w, lr, contentLength); err != nil {
+		reader := newThrottledReader(ctx, lr, config.ValueOf.StreamBandwidth)
+		metrics.ActiveStreams.Add(1)
+		n, err := io.CopyN(w, reader, contentLength)
+		metrics.ActiveStreams.Add(-1)
+		metrics.BytesServed.Add(float64(n))
+		recordTraffic(ctx, messageID, n)
+		if err != nil {
 			log.Error("Error while copying stream", zap.Error(err))
 		}
 	}
 }
+
+// recordTraffic counts a request for a file in the traffic stats, with the
+// client and where it came from.
+func recordTraffic(ctx *gin.Context, messageID int, bytes int64) {
+	var referrer string
+	if ref, err := url.Parse(ctx.Request.Referer()); err == nil {
+		referrer = strings.ToLower(ref.Hostname())
+	}
+	cache.GetStatsCache().RecordTraffic(cache.TrafficHit{
+		MessageID: messageID,
+		Bytes:     bytes,
+		Viewer:    utils.HashIP(ctx.ClientIP()),
+		Referrer:  referrer,
+		Country:   utils.CountryOf(ctx.ClientIP()),
+	})
+}
+
+// checkLink writes an error and returns false if the links of the message
+// were revoked or expired, or its owner is banned.
+func checkLink(w http.ResponseWriter, messageID int) bool {
+	_, ok := checkLinkOwner(w, messageID)
+	return ok
+}
+
+// checkLinkOwner is checkLink that also returns the owner of the file.
+func checkLinkOwner(w http.ResponseWriter, messageID int) (int64, bool) {
+	ownerID, err := database.CheckLink(messageID)
+	switch {
+	case errors.Is(err, database.ErrLinkRevoked), errors.Is(err, database.ErrLinkExpired):
+		http.Error(w, err.Error(), http.StatusGone)
+	case errors.Is(err, database.ErrOwnerBanned):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case err != nil:
+		log.Error("Failed to check link", zap.Error(err), zap.Int("messageID", messageID))
+		http.Error(w, "failed to check link, try again later", http.StatusServiceUnavailable)
+	default:
+		return ownerID, true
+	}
+	return ownerID, false
+}
+
+// checkViewerToken writes an error and returns false if VIEWER_TOKENS is
+// set and the request doesn't carry a valid token for scope. It also returns
+// the ID the token was issued to.
+func checkViewerToken(w http.ResponseWriter, token string, scope string) (int64, bool) {
+	if !config.ValueOf.ViewerTokens {
+		return 0, true
+	}
+	viewerID, err := utils.CheckViewerToken(token, scope)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return 0, false
+	}
+	return viewerID, true
+}
+
+// activeStreams counts the streams being served per file owner, for
+// MAX_STREAMS_PER_USER.
+var activeStreams = struct {
+	sync.Mutex
+	count map[int64]int64
+}{count: make(map[int64]int64)}
+
+// acquireStream reserves a stream of the owner's files, returning false if
+// the owner already has as many as their quota allows. release must be
+// called once the stream ends.
+func acquireStream(ownerID int64) (release func(), ok bool) {
+	if ownerID == 0 {
+		return func() {}, true
+	}
+	limit := database.GetUserQuota(ownerID).MaxStreams
+	activeStreams.Lock()
+	defer activeStreams.Unlock()
+	if limit > 0 && activeStreams.count[ownerID] >= limit {
+		return nil, false
+	}
+	activeStreams.count[ownerID]++
+	return func() {
+		activeStreams.Lock()
+		defer activeStreams.Unlock()
+		if activeStreams.count[ownerID]--; activeStreams.count[ownerID] <= 0 {
+			delete(activeStreams.count, ownerID)
+		}
+	}, true
+}
@@ -0,0 +1,79 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/bot"
+	"EverythingSuckz/fsb/internal/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotd/td/tg"
+)
+
+func (r *allRoutes) LoadThumb(route *Route) {
+	route.Engine.GET("/thumb/:messageID", r.getThumb)
+}
+
+// getThumb serves the thumbnail Telegram generated for a document, used by
+// inline query results.
+func (r *allRoutes) getThumb(c *gin.Context) {
+	messageID, err := strconv.Atoi(c.Param("messageID"))
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	authHash := c.Query("hash")
+	if authHash == "" {
+		http.Error(c.Writer, "missing hash param", http.StatusBadRequest)
+		return
+	}
+
+	worker := bot.GetNextWorker()
+	file, err := utils.FileFromMessage(c, worker.Client, messageID)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	expectedHash := utils.PackFile(file.FileName, file.FileSize, file.MimeType, file.ID)
+	if !utils.CheckHash(authHash, expectedHash) {
+		http.Error(c.Writer, "invalid hash", http.StatusBadRequest)
+		return
+	}
+	if _, ok := checkViewerToken(c.Writer, c.Query("token"), utils.FileScope(messageID)); !ok {
+		return
+	}
+	if !checkLink(c.Writer, messageID) {
+		return
+	}
+
+	var location tg.InputDocumentFileLocation
+	switch l := file.Location.(type) {
+	case *tg.InputDocumentFileLocation:
+		location = *l
+	default:
+		http.Error(c.Writer, "file has no thumbnail", http.StatusNotFound)
+		return
+	}
+	if file.Thumb == "" {
+		http.Error(c.Writer, "file has no thumbnail", http.StatusNotFound)
+		return
+	}
+	location.ThumbSize = file.Thumb
+
+	res, err := worker.Client.API().UploadGetFile(c, &tg.UploadGetFileRequest{
+		Location: &location,
+		Offset:   0,
+		Limit:    1024 * 1024,
+	})
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	result, ok := res.(*tg.UploadFile)
+	if !ok {
+		http.Error(c.Writer, "unexpected response", http.StatusInternalServerError)
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, "image/jpeg", result.GetBytes())
+}
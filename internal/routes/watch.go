@@ -0,0 +1,88 @@
+package routes
+
+import (
+	"EverythingSuckz/fsb/internal/bot"
+	"EverythingSuckz/fsb/internal/utils"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var watchPage = template.Must(template.New("watch").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.FileName}}</title>
+<style>
+body{margin:0;background:#111;color:#eee;font-family:sans-serif;display:flex;flex-direction:column;align-items:center}
+video,audio{width:100%;max-width:960px;max-height:80vh;background:#000}
+h1{font-size:1.1em;word-break:break-all;padding:0 1em}
+a{color:#8ab4f8}
+</style>
+</head>
+<body>
+<h1>{{.FileName}}</h1>
+{{if .IsAudio}}<audio controls autoplay src="{{.StreamURL}}"></audio>{{else}}<video controls autoplay playsinline src="{{.StreamURL}}"></video>{{end}}
+<p><a href="{{.DownloadURL}}">Download</a></p>
+</body>
+</html>`))
+
+type watchPageData struct {
+	FileName    string
+	StreamURL   string
+	DownloadURL string
+	IsAudio     bool
+}
+
+func (r *allRoutes) LoadWatch(route *Route) {
+	route.Engine.GET("/watch/:messageID", r.getWatchPage)
+}
+
+// getWatchPage renders a minimal player for a file.
+func (r *allRoutes) getWatchPage(c *gin.Context) {
+	messageID, err := strconv.Atoi(c.Param("messageID"))
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	authHash := c.Query("hash")
+	if authHash == "" {
+		http.Error(c.Writer, "missing hash param", http.StatusBadRequest)
+		return
+	}
+
+	worker := bot.GetNextWorker()
+	file, err := utils.FileFromMessage(c, worker.Client, messageID)
+	if err != nil {
+		http.Error(c.Writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	expectedHash := utils.PackFile(file.FileName, file.FileSize, file.MimeType, file.ID)
+	if !utils.CheckHash(authHash, expectedHash) {
+		http.Error(c.Writer, "invalid hash", http.StatusBadRequest)
+		return
+	}
+	token := c.Query("token")
+	if _, ok := checkViewerToken(c.Writer, token, utils.FileScope(messageID)); !ok {
+		return
+	}
+	if !checkLink(c.Writer, messageID) {
+		return
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	err = watchPage.Execute(c.Writer, watchPageData{
+		FileName:    file.FileName,
+		StreamURL:   utils.WithViewerToken(utils.GetStreamLink(messageID, expectedHash), token),
+		DownloadURL: utils.WithViewerToken(utils.GetDownloadLink(messageID, expectedHash), token),
+		IsAudio:     strings.HasPrefix(file.MimeType, "audio/"),
+	})
+	if err != nil {
+		r.log.Error("Failed to render watch page", zap.Error(err))
+	}
+}
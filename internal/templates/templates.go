@@ -0,0 +1,225 @@
+package templates
+
+import (
+	"EverythingSuckz/fsb/config"
+	"embed"
+	"fmt"
+	"html"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/gotd/td/telegram/message/entity"
+	tghtml "github.com/gotd/td/telegram/message/html"
+	"github.com/gotd/td/telegram/message/styling"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// DefaultLocale is used for users whose language has no locale, and for any
+// message missing from a locale.
+const DefaultLocale = "en"
+
+//go:embed locales/*.tmpl
+var localeFiles embed.FS
+
+var (
+	sets    map[string]*template.Template
+	locales []string
+	log     *zap.Logger
+)
+
+// Load parses the built-in locales followed by TEMPLATES_FILE. A definition
+// such as "start" there overrides the message of the default locale, and of
+// the locales that fall back to it, while "start.es" overrides the message
+// of one locale. Templates that use a field their data doesn't have fail
+// instead of printing "<no value>".
+func Load(l *zap.Logger) error {
+	log = l.Named("templates")
+	base, err := template.New("messages").Option("missingkey=error").ParseFS(localeFiles, "locales/"+DefaultLocale+".tmpl")
+	if err != nil {
+		return err
+	}
+	entries, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		return err
+	}
+	var overrides []*template.Template
+	if file := config.ValueOf.TemplatesFile; file != "" {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		parsed, err := template.New(file).Parse(string(content))
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		overrides = parsed.Templates()
+		log.Info("Loaded templates from file", zap.String("path", file))
+	}
+
+	sets = make(map[string]*template.Template, len(entries))
+	locales = locales[:0]
+	for _, entry := range entries {
+		name := entry.Name()
+		locale := strings.TrimSuffix(name, path.Ext(name))
+		// every locale starts as a copy of the default one, so missing
+		// messages fall back to it
+		t, err := base.Clone()
+		if err != nil {
+			return err
+		}
+		// the messages this locale translates
+		own := make(map[string]bool)
+		if locale != DefaultLocale {
+			translated, err := template.New(name).ParseFS(localeFiles, "locales/"+name)
+			if err != nil {
+				return err
+			}
+			for _, message := range translated.Templates() {
+				own[message.Name()] = true
+			}
+			if _, err := t.ParseFS(localeFiles, "locales/"+name); err != nil {
+				return err
+			}
+		}
+		for _, override := range overrides {
+			if override.Tree == nil || override.Name() == config.ValueOf.TemplatesFile {
+				continue
+			}
+			message, overrideLocale, found := strings.Cut(override.Name(), ".")
+			if found && overrideLocale != locale || !found && own[message] {
+				continue
+			}
+			if _, err := t.AddParseTree(message, override.Tree); err != nil {
+				return fmt.Errorf("%s: %w", config.ValueOf.TemplatesFile, err)
+			}
+		}
+		if caption := config.ValueOf.ChannelCaption; caption != "" {
+			if _, err := t.New("channel_caption").Parse(caption); err != nil {
+				return fmt.Errorf("CHANNEL_CAPTION_TEMPLATE: %w", err)
+			}
+		}
+		sets[locale] = t
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	log.Info("Initialized", zap.Strings("locales", locales))
+	return nil
+}
+
+// Locales returns the available locales, sorted.
+func Locales() []string {
+	return locales
+}
+
+// Locale returns the locale for a Telegram language code such as "es" or
+// "pt-br", or DefaultLocale if there is none.
+func Locale(langCode string) string {
+	langCode = strings.ToLower(langCode)
+	if _, ok := sets[langCode]; ok {
+		return langCode
+	}
+	if i := strings.IndexAny(langCode, "-_"); i > 0 {
+		if _, ok := sets[langCode[:i]]; ok {
+			return langCode[:i]
+		}
+	}
+	return DefaultLocale
+}
+
+// Render executes the named template of a locale and parses the output as
+// Telegram HTML (<b>, <i>, <code>, <a href="">...), ready to be passed to
+// ctx.Reply.
+func Render(locale, name string, data interface{}) (styling.StyledTextOption, error) {
+	text, err := execute(locale, name, data)
+	if err != nil {
+		return styling.StyledTextOption{}, err
+	}
+	return tghtml.String(nil, text), nil
+}
+
+// RenderText is like Render but returns the text and its entities, for
+// requests that take them separately such as edits and inline results.
+func RenderText(locale, name string, data interface{}) (string, []tg.MessageEntityClass, error) {
+	option, err := Render(locale, name, data)
+	if err != nil {
+		return "", nil, err
+	}
+	var builder entity.Builder
+	if err := styling.Perform(&builder, option); err != nil {
+		return "", nil, err
+	}
+	text, entities := builder.Complete()
+	return text, entities, nil
+}
+
+// Plain renders a template without formatting, e.g. for button labels and
+// callback answers. Errors are logged and the template name is returned.
+func Plain(locale, name string, data interface{}) string {
+	text, _, err := RenderText(locale, name, data)
+	if err != nil {
+		log.Error("Failed to render template", zap.String("name", name), zap.Error(err))
+		return name
+	}
+	return text
+}
+
+func execute(locale, name string, data interface{}) (string, error) {
+	t, ok := sets[locale]
+	if !ok {
+		t = sets[DefaultLocale]
+	}
+	var sb strings.Builder
+	if err := t.ExecuteTemplate(&sb, name, escape(data)); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// escape HTML-escapes every string in data, so values such as file names
+// are shown as they are instead of being parsed as markup. Structs are
+// turned into maps, which templates access the same way.
+func escape(data interface{}) interface{} {
+	switch value := data.(type) {
+	case nil:
+		return nil
+	case string:
+		return html.EscapeString(value)
+	case fmt.Stringer:
+		return value
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			out[k] = escape(v)
+		}
+		return out
+	}
+	val := reflect.ValueOf(data)
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	switch val.Kind() {
+	case reflect.Struct:
+		out := make(map[string]interface{}, val.NumField())
+		for i := 0; i < val.NumField(); i++ {
+			if field := val.Type().Field(i); field.IsExported() {
+				out[field.Name] = escape(val.Field(i).Interface())
+			}
+		}
+		return out
+	case reflect.Slice:
+		out := make([]interface{}, val.Len())
+		for i := range out {
+			out[i] = escape(val.Index(i).Interface())
+		}
+		return out
+	}
+	return data
+}
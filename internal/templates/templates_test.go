@@ -0,0 +1,148 @@
+package templates
+
+import (
+	"EverythingSuckz/fsb/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// load runs Load with TEMPLATES_FILE holding content, if any, and
+// CHANNEL_CAPTION_TEMPLATE set to caption.
+func load(t *testing.T, content, caption string) error {
+	t.Helper()
+	previous := *config.ValueOf
+	t.Cleanup(func() { *config.ValueOf = previous })
+	config.ValueOf.TemplatesFile = ""
+	if content != "" {
+		file := filepath.Join(t.TempDir(), "messages.tmpl")
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		config.ValueOf.TemplatesFile = file
+	}
+	config.ValueOf.ChannelCaption = caption
+	return Load(zap.NewNop())
+}
+
+func mustExecute(t *testing.T, locale, name string, data interface{}) string {
+	t.Helper()
+	text, err := execute(locale, name, data)
+	if err != nil {
+		t.Fatalf("execute(%q, %q): %v", locale, name, err)
+	}
+	return text
+}
+
+func TestLoadBuiltIn(t *testing.T) {
+	if err := load(t, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := Locales(); strings.Join(got, ",") != "en,es" {
+		t.Errorf("Locales() = %v, want [en es]", got)
+	}
+	if got := mustExecute(t, "es", "banned", nil); !strings.Contains(got, "Tienes prohibido") {
+		t.Errorf("es banned = %q, want the Spanish translation", got)
+	}
+	// es has no channel_caption of its own
+	data := map[string]interface{}{"Caption": "c", "StreamURL": "s", "DownloadURL": "d"}
+	if en, es := mustExecute(t, "en", "channel_caption", data), mustExecute(t, "es", "channel_caption", data); en != es {
+		t.Errorf("es channel_caption = %q, want the en fallback %q", es, en)
+	}
+	// unknown locales use the default one
+	if got, want := mustExecute(t, "fr", "banned", nil), mustExecute(t, "en", "banned", nil); got != want {
+		t.Errorf("fr banned = %q, want %q", got, want)
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	err := load(t, `
+{{define "banned"}}Go away.{{end}}
+{{define "quota_files.es"}}Máximo {{.Max}}.{{end}}
+{{define "channel_caption"}}{{.Caption}} | {{.StreamURL}}{{end}}
+`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		locale, name string
+		data         interface{}
+		want         string
+	}{
+		// a plain name overrides the default locale...
+		{"en", "banned", nil, "Go away."},
+		// ...but not the locales that translate the message
+		{"es", "banned", nil, "🚫 Tienes prohibido usar este bot."},
+		// and the locales that fall back to it get the override too
+		{"es", "channel_caption", map[string]interface{}{"Caption": "c", "StreamURL": "s"}, "c | s"},
+		// a name with a locale only overrides that locale
+		{"es", "quota_files", map[string]interface{}{"Max": 3}, "Máximo 3."},
+		{"en", "quota_files", map[string]interface{}{"Max": 3}, "⚠️ You reached your limit of <b>3</b> files per day. Try again tomorrow."},
+	} {
+		if got := mustExecute(t, tt.locale, tt.name, tt.data); got != tt.want {
+			t.Errorf("%s %s = %q, want %q", tt.locale, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoadChannelCaption(t *testing.T) {
+	if err := load(t, `{{define "channel_caption"}}from file{{end}}`, "{{.FileName}}: {{.StreamURL}}"); err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{"FileName": "a&b.mp4", "StreamURL": "s"}
+	// CHANNEL_CAPTION_TEMPLATE wins over the file, in every locale, and the
+	// values are escaped
+	for _, locale := range []string{"en", "es"} {
+		if got := mustExecute(t, locale, "channel_caption", data); got != "a&amp;b.mp4: s" {
+			t.Errorf("%s channel_caption = %q, want %q", locale, got, "a&amp;b.mp4: s")
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	if err := load(t, `{{define "banned"}}{{.Missing}`, ""); err == nil {
+		t.Error("Load with a broken TEMPLATES_FILE = nil, want an error")
+	}
+	if err := load(t, "", "{{if}}"); err == nil {
+		t.Error("Load with a broken CHANNEL_CAPTION_TEMPLATE = nil, want an error")
+	}
+	config.ValueOf.TemplatesFile = filepath.Join(t.TempDir(), "missing.tmpl")
+	if err := Load(zap.NewNop()); err == nil {
+		t.Error("Load with a missing TEMPLATES_FILE = nil, want an error")
+	}
+	config.ValueOf.TemplatesFile = ""
+}
+
+func TestExecuteMissingField(t *testing.T) {
+	if err := load(t, `{{define "banned"}}Banned until {{.Until}}.{{end}}`, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := execute("en", "banned", map[string]interface{}{}); err == nil {
+		t.Error("execute with a missing field = nil, want an error")
+	}
+	if got := mustExecute(t, "en", "banned", struct{ Until string }{"<tomorrow>"}); got != "Banned until &lt;tomorrow&gt;." {
+		t.Errorf("banned = %q, want the value escaped", got)
+	}
+}
+
+func TestLocale(t *testing.T) {
+	if err := load(t, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	for code, want := range map[string]string{
+		"es":    "es",
+		"ES":    "es",
+		"es-MX": "es",
+		"es_ar": "es",
+		"en-GB": "en",
+		"pt-br": DefaultLocale,
+		"":      DefaultLocale,
+	} {
+		if got := Locale(code); got != want {
+			t.Errorf("Locale(%q) = %q, want %q", code, got, want)
+		}
+	}
+}
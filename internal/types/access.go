@@ -0,0 +1,30 @@
+package types
+
+import (
+	"time"
+)
+
+// BannedUser is a user banned by an admin with /ban.
+type BannedUser struct {
+	UserID    int64 `gorm:"primaryKey;autoIncrement:false"`
+	Reason    string
+	BannedBy  int64     // admin who ran /ban
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// AllowedUser is a user added to the allow list by an admin with /allow.
+type AllowedUser struct {
+	UserID    int64     `gorm:"primaryKey;autoIncrement:false"`
+	AddedBy   int64     // admin who ran /allow
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for AllowedUser
+func (AllowedUser) TableName() string {
+	return "allowed_users"
+}
+
+// TableName specifies the table name for BannedUser
+func (BannedUser) TableName() string {
+	return "banned_users"
+}
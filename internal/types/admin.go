@@ -0,0 +1,43 @@
+package types
+
+import (
+	"time"
+)
+
+// AuditLog records every use of an admin command.
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement"`
+	AdminID   int64     `gorm:"index;not null"`
+	Action    string    `gorm:"index;not null"` // command name
+	Args      string    // command arguments
+	CreatedAt time.Time `gorm:"autoCreateTime;index"`
+}
+
+// UserUsage is the amount of files a user has generated links for.
+type UserUsage struct {
+	OwnerID int64
+	Files   int64
+	Bytes   int64
+}
+
+// WorkerInfo describes one of the bots serving files.
+type WorkerInfo struct {
+	ID       int
+	UserID   int64
+	Username string
+}
+
+// CacheStats holds the counters of the file metadata cache.
+type CacheStats struct {
+	Entries   int64
+	Hits      int64
+	Misses    int64
+	HitRate   float64
+	Evacuated int64 // entries removed to make room for new ones
+	Expired   int64
+}
+
+// TableName specifies the table name for AuditLog
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
@@ -0,0 +1,45 @@
+package types
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// ChatModeReply replies to media messages with the links.
+	ChatModeReply = "reply"
+	// ChatModeCaption appends the links to the caption of channel posts.
+	ChatModeCaption = "caption"
+	// ChatModeButtons attaches the links as inline URL buttons to channel posts.
+	ChatModeButtons = "buttons"
+)
+
+// DefaultChatMediaTypes are the media types linked in a newly enabled chat.
+const DefaultChatMediaTypes = "video,audio,document,photo"
+
+// ChatSettings holds the per-chat settings of groups and channels where an
+// admin opted in with /enable.
+type ChatSettings struct {
+	ChatID     int64     `gorm:"primaryKey;autoIncrement:false"`
+	Enabled    bool      `gorm:"not null;default:false"`
+	Mode       string    `gorm:"not null;default:reply"`
+	MediaTypes string    `gorm:"not null"` // comma separated, see DefaultChatMediaTypes
+	EnabledBy  int64     // user who last ran /enable
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+}
+
+// AllowsMedia reports whether links should be generated for the given media type.
+func (s *ChatSettings) AllowsMedia(mediaType string) bool {
+	for _, t := range strings.Split(s.MediaTypes, ",") {
+		if strings.TrimSpace(t) == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// TableName specifies the table name for ChatSettings
+func (ChatSettings) TableName() string {
+	return "chat_settings"
+}
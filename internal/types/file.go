@@ -15,6 +15,9 @@ type File struct {
 	FileName string
 	MimeType string
 	ID       int64
+	Duration int    // in seconds, 0 if unknown
+	Title    string // audio title / performer if present
+	Thumb    string // thumbnail size type, empty if the file has none
 }
 
 type HashableFileStruct struct {
@@ -0,0 +1,30 @@
+package types
+
+import (
+	"time"
+)
+
+// Quota holds the limits that apply to a user. Zero means unlimited.
+type Quota struct {
+	FilesPerDay int64
+	BytesPerDay int64
+	MaxFileSize int64 // in bytes
+	MaxStreams  int64 // concurrent streams of the user's files
+}
+
+// UserLimits overrides the default quota of one user, set by an admin with
+// /setlimit. Nil fields keep the default from the config.
+type UserLimits struct {
+	UserID      int64 `gorm:"primaryKey;autoIncrement:false"`
+	FilesPerDay *int64
+	BytesPerDay *int64
+	MaxFileSize *int64
+	MaxStreams  *int64
+	SetBy       int64     // admin who last ran /setlimit
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for UserLimits
+func (UserLimits) TableName() string {
+	return "user_limits"
+}
@@ -6,3 +6,17 @@ type RootResponse struct {
 	Uptime  string `json:"uptime"`
 	Version string `json:"version"`
 }
+
+// HealthCheck is the result of one check of /readyz
+type HealthCheck struct {
+	Ok      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+	Latency string `json:"latency"`
+	Healthy int    `json:"healthy,omitempty"` // workers that answered
+	Total   int    `json:"total,omitempty"`   // workers checked
+}
+
+type ReadinessResponse struct {
+	Ready  bool                   `json:"ready"`
+	Checks map[string]HealthCheck `json:"checks"`
+}
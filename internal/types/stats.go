@@ -7,7 +7,18 @@ import (
 // Stats represents the statistics for file processing
 type Stats struct {
 	ID        uint      `gorm:"primaryKey;autoIncrement"`
-	Date      time.Time `gorm:"index;not null"`
+	Date      time.Time `gorm:"uniqueIndex:idx_file_stats_day;not null"`
+	FileCount int64     `gorm:"not null;default:0"`
+	TotalSize int64     `gorm:"not null;default:0"` // in bytes
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// UserStats represents the files a user processed on one day, used for quotas
+type UserStats struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement"`
+	UserID    int64     `gorm:"uniqueIndex:idx_user_stats_day;not null"`
+	Date      time.Time `gorm:"uniqueIndex:idx_user_stats_day;not null"`
 	FileCount int64     `gorm:"not null;default:0"`
 	TotalSize int64     `gorm:"not null;default:0"` // in bytes
 	CreatedAt time.Time `gorm:"autoCreateTime"`
@@ -16,28 +27,77 @@ type Stats struct {
 
 // DailyStats represents today's statistics
 type DailyStats struct {
-	Date      time.Time `json:"date"`
-	FileCount int64     `json:"file_count"`
-	TotalSize int64     `json:"total_size"` // in bytes
+	Date      time.Time    `json:"date"`
+	FileCount int64        `json:"file_count"`
+	TotalSize int64        `json:"total_size"` // in bytes
+	Traffic   TrafficStats `json:"traffic"`
 }
 
 // WeeklyStats represents the last 7 days statistics
 type WeeklyStats struct {
-	StartDate time.Time `json:"start_date"`
-	EndDate   time.Time `json:"end_date"`
-	FileCount int64     `json:"file_count"`
-	TotalSize int64     `json:"total_size"` // in bytes
+	StartDate time.Time    `json:"start_date"`
+	EndDate   time.Time    `json:"end_date"`
+	FileCount int64        `json:"file_count"`
+	TotalSize int64        `json:"total_size"` // in bytes
+	Traffic   TrafficStats `json:"traffic"`
 }
 
 // StatisticsResponse represents the complete statistics response
 type StatisticsResponse struct {
-	Today     DailyStats  `json:"today"`
-	Yesterday DailyStats  `json:"yesterday"`
-	LastWeek  WeeklyStats `json:"last_week"`
-	Total     DailyStats  `json:"total"`
+	Today     DailyStats         `json:"today"`
+	Yesterday DailyStats         `json:"yesterday"`
+	LastWeek  WeeklyStats        `json:"last_week"`
+	Total     DailyStats         `json:"total"`
+	TopFiles  []FileTrafficStats `json:"top_files"` // most served in the last 7 days
+}
+
+// PublicStatisticsResponse is a StatisticsResponse without the message IDs
+// of the top files, which would lead to the files themselves
+type PublicStatisticsResponse struct {
+	Today     DailyStats     `json:"today"`
+	Yesterday DailyStats     `json:"yesterday"`
+	LastWeek  WeeklyStats    `json:"last_week"`
+	Total     DailyStats     `json:"total"`
+	TopFiles  []TrafficStats `json:"top_files"` // most served in the last 7 days
+}
+
+// Public returns the statistics that can be served to anyone
+func (s StatisticsResponse) Public() PublicStatisticsResponse {
+	topFiles := make([]TrafficStats, 0, len(s.TopFiles))
+	for _, file := range s.TopFiles {
+		topFiles = append(topFiles, file.TrafficStats)
+	}
+	return PublicStatisticsResponse{
+		Today:     s.Today,
+		Yesterday: s.Yesterday,
+		LastWeek:  s.LastWeek,
+		Total:     s.Total,
+		TopFiles:  topFiles,
+	}
+}
+
+// Granularities of a range of statistics
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// PeriodStats represents the statistics of a period in a range
+type PeriodStats struct {
+	Start     string       `json:"start"` // first day, YYYY-MM-DD
+	End       string       `json:"end"`   // last day, included
+	FileCount int64        `json:"file_count"`
+	TotalSize int64        `json:"total_size"` // in bytes
+	Traffic   TrafficStats `json:"traffic"`
 }
 
 // TableName specifies the table name for Stats
 func (Stats) TableName() string {
 	return "file_stats"
 } 
+
+// TableName specifies the table name for UserStats
+func (UserStats) TableName() string {
+	return "user_stats"
+}
@@ -0,0 +1,90 @@
+package types
+
+import (
+	"time"
+)
+
+// FileTraffic represents the bytes served and requests made for a file on one day
+type FileTraffic struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement"`
+	MessageID int       `gorm:"uniqueIndex:idx_file_traffic_day;not null"` // message ID in LOG_CHANNEL
+	Date      time.Time `gorm:"uniqueIndex:idx_file_traffic_day;index;not null"`
+	Bytes     int64     `gorm:"not null;default:0"`
+	Requests  int64     `gorm:"not null;default:0"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// TrafficViewer records that a viewer requested a file on one day, to count
+// unique viewers. Viewer is a keyed hash of the client IP, see utils.HashIP.
+type TrafficViewer struct {
+	MessageID int       `gorm:"primaryKey;autoIncrement:false"`
+	Date      time.Time `gorm:"primaryKey;index"`
+	Viewer    string    `gorm:"primaryKey"`
+}
+
+// Kinds of TrafficSource
+const (
+	TrafficReferrer = "referrer"
+	TrafficCountry  = "country"
+)
+
+// TrafficSource counts the requests for a file on one day by where they
+// came from: the host of the referring page or the country of the client.
+type TrafficSource struct {
+	MessageID int       `gorm:"primaryKey;autoIncrement:false"`
+	Date      time.Time `gorm:"primaryKey;index"`
+	Kind      string    `gorm:"primaryKey"` // TrafficReferrer or TrafficCountry
+	Value     string    `gorm:"primaryKey"`
+	Requests  int64     `gorm:"not null;default:0"`
+}
+
+// TrafficStats represents the traffic of a period
+type TrafficStats struct {
+	Bytes         int64 `json:"bytes_served"`
+	Requests      int64 `json:"requests"`
+	UniqueViewers int64 `json:"unique_viewers"`
+}
+
+// FileTrafficStats represents the traffic of one file
+type FileTrafficStats struct {
+	MessageID int `json:"message_id"`
+	TrafficStats
+}
+
+// SourceCount is the number of requests from a referrer or country
+type SourceCount struct {
+	Value    string `json:"value"`
+	Requests int64  `json:"requests"`
+}
+
+// DailyViews represents the traffic of one file on one day
+type DailyViews struct {
+	Date time.Time `json:"date"`
+	TrafficStats
+}
+
+// LinkStats represents the views of one file, for its owner
+type LinkStats struct {
+	MessageID int           `json:"message_id"`
+	FileName  string        `json:"file_name"`
+	Since     time.Time     `json:"since"`
+	Total     TrafficStats  `json:"total"`
+	Days      []DailyViews  `json:"days"`
+	Referrers []SourceCount `json:"referrers"`
+	Countries []SourceCount `json:"countries"`
+}
+
+// TableName specifies the table name for FileTraffic
+func (FileTraffic) TableName() string {
+	return "file_traffic"
+}
+
+// TableName specifies the table name for TrafficViewer
+func (TrafficViewer) TableName() string {
+	return "traffic_viewers"
+}
+
+// TableName specifies the table name for TrafficSource
+func (TrafficSource) TableName() string {
+	return "traffic_sources"
+}
@@ -0,0 +1,50 @@
+package types
+
+import (
+	"time"
+)
+
+// Reply formats of the link messages.
+const (
+	ReplyFormatButtons = "buttons" // link followed by the LINK_BUTTONS keyboard
+	ReplyFormatText    = "text"    // every link as text, without buttons
+)
+
+// UserSettings holds the per-user preferences set through bot commands.
+// The zero value of each field is the default.
+type UserSettings struct {
+	UserID        int64     `gorm:"primaryKey;autoIncrement:false"`
+	Language      string    // locale chosen with /language, empty to follow Telegram
+	LinkLifetime  int64     `gorm:"not null;default:0"` // seconds until new links expire, 0 for never
+	ReplyFormat   string    // ReplyFormatButtons or ReplyFormatText, empty for buttons
+	HideWatchLink bool      `gorm:"not null;default:false"`
+	NoLibrary     bool      `gorm:"not null;default:false"` // keep new files out of the inline library
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+}
+
+// TextReplies reports whether links are sent as text instead of buttons.
+func (s *UserSettings) TextReplies() bool {
+	return s.ReplyFormat == ReplyFormatText
+}
+
+// TableName specifies the table name for UserSettings
+func (UserSettings) TableName() string {
+	return "user_settings"
+}
+
+// User is someone who has used the bot in a private chat, the audience of
+// /broadcast.
+type User struct {
+	UserID    int64 `gorm:"primaryKey;autoIncrement:false"`
+	Username  string
+	FirstName string
+	Inactive  bool `gorm:"index;not null;default:false"` // set once the user blocks the bot
+	LastSeen  time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for User
+func (User) TableName() string {
+	return "users"
+}
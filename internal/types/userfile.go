@@ -0,0 +1,47 @@
+package types
+
+import (
+	"time"
+)
+
+// UserFile is a file a user has generated links for, keyed by its
+// message ID in the log channel.
+type UserFile struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement"`
+	OwnerID    int64  `gorm:"index;not null"`
+	ChatID     int64  `gorm:"index"`                // group or channel the file was posted in, 0 for private chats
+	MessageID  int    `gorm:"uniqueIndex;not null"` // message ID in LOG_CHANNEL
+	Hash       string `gorm:"not null"`             // full hash, see utils.PackFile
+	FileName   string `gorm:"not null"`
+	FileSize   int64  `gorm:"not null;default:0"` // in bytes
+	MimeType   string
+	Duration   int        // in seconds
+	Title      string     // audio title if present
+	HasThumb   bool       `gorm:"not null;default:false"`
+	GroupedID  int64      `gorm:"index"` // album the original message belonged to
+	PlaylistID string     `gorm:"index"`
+	Revoked    bool       `gorm:"not null;default:false"` // links stop working once set
+	ExpiresAt  *time.Time `gorm:"index"`                  // links stop working after this, nil for never
+	Hidden     bool       `gorm:"not null;default:false"` // left out of the inline library
+	CreatedAt  time.Time  `gorm:"autoCreateTime"`
+}
+
+// Playlist groups files of an album or a bundle built with /bundle.
+type Playlist struct {
+	ID        string `gorm:"primaryKey"`
+	OwnerID   int64  `gorm:"index;uniqueIndex:idx_playlist_album,where:grouped_id <> 0;not null"`
+	Title     string
+	GroupedID int64     `gorm:"index;uniqueIndex:idx_playlist_album,where:grouped_id <> 0"` // non-zero for album playlists, one per album
+	Open      bool      `gorm:"not null;default:false"` // bundle still collecting files
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for UserFile
+func (UserFile) TableName() string {
+	return "user_files"
+}
+
+// TableName specifies the table name for Playlist
+func (Playlist) TableName() string {
+	return "playlists"
+}
@@ -0,0 +1,242 @@
+package utils
+
+import (
+	"EverythingSuckz/fsb/config"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"go.uber.org/zap"
+)
+
+// ForceSubChannel is a channel users have to join before using the bot.
+type ForceSubChannel struct {
+	// Username of a public channel, without the @.
+	Username string
+	// ID of a private channel, without the -100 prefix.
+	ID int64
+	// JoinURL is the t.me link of a public channel or the invite link of a
+	// private one.
+	JoinURL string
+}
+
+func (c ForceSubChannel) key() string {
+	if c.Username != "" {
+		return c.Username
+	}
+	return strconv.FormatInt(c.ID, 10)
+}
+
+type subscription struct {
+	subscribed bool
+	expires    time.Time
+}
+
+var (
+	forceSubOnce     sync.Once
+	forceSubChannels []ForceSubChannel
+
+	forceSubMutex sync.Mutex
+	// resolved input channels, by ForceSubChannel.key()
+	forceSubPeers = make(map[string]*tg.InputChannel)
+	// membership results, by user ID and ForceSubChannel.key()
+	subscriptions = make(map[int64]map[string]subscription)
+)
+
+// ForceSubChannels returns the channels of FORCE_SUB_CHANNELS, plus the one
+// of the older FORCE_SUB_CHANNEL. Entries are either a public username or
+// a private channel ID followed by its invite link, as in
+// "-1001234567890=https://t.me/+AbCdEf".
+func ForceSubChannels() []ForceSubChannel {
+	forceSubOnce.Do(func() {
+		entries := config.ValueOf.ForceSubs
+		if config.ValueOf.ForceSubChannel != "" {
+			entries = append([]string{config.ValueOf.ForceSubChannel}, entries...)
+		}
+		for _, entry := range entries {
+			channel, err := parseForceSubChannel(entry)
+			if err != nil {
+				Logger.Warn("Ignoring force-sub channel", zap.String("channel", entry), zap.Error(err))
+				continue
+			}
+			forceSubChannels = append(forceSubChannels, channel)
+		}
+	})
+	return forceSubChannels
+}
+
+func parseForceSubChannel(entry string) (ForceSubChannel, error) {
+	entry = strings.TrimSpace(entry)
+	id, joinURL, hasLink := strings.Cut(entry, "=")
+	if !hasLink {
+		username := strings.TrimPrefix(strings.TrimPrefix(entry, "https://t.me/"), "@")
+		if username == "" {
+			return ForceSubChannel{}, fmt.Errorf("empty channel")
+		}
+		if _, err := strconv.ParseInt(username, 10, 64); err == nil {
+			return ForceSubChannel{}, fmt.Errorf("private channels need an invite link, as in %s=https://t.me/+...", username)
+		}
+		return ForceSubChannel{Username: username, JoinURL: "https://t.me/" + username}, nil
+	}
+	channelID, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+	if err != nil {
+		return ForceSubChannel{}, fmt.Errorf("invalid channel ID: %w", err)
+	}
+	// same as the log channel, "-1001234567890" becomes 1234567890
+	channelID, _ = strconv.ParseInt(strings.TrimPrefix(strconv.FormatInt(channelID, 10), "-100"), 10, 64)
+	if channelID < 0 {
+		channelID = -channelID
+	}
+	return ForceSubChannel{ID: channelID, JoinURL: strings.TrimSpace(joinURL)}, nil
+}
+
+// MissingSubscriptions returns the force-sub channels the user hasn't
+// joined. Results are cached for FORCE_SUB_CACHE_TTL. An error means the
+// membership couldn't be checked, usually because the bot isn't an admin
+// of one of the channels.
+func MissingSubscriptions(ctx context.Context, client *tg.Client, peerStorage *storage.PeerStorage, userID int64) ([]ForceSubChannel, error) {
+	var missing []ForceSubChannel
+	for _, channel := range ForceSubChannels() {
+		subscribed, ok := cachedSubscription(userID, channel)
+		if !ok {
+			var err error
+			subscribed, err = checkSubscription(ctx, client, peerStorage, userID, channel)
+			if err != nil {
+				return nil, fmt.Errorf("channel %s: %w", channel.key(), err)
+			}
+			cacheSubscription(userID, channel, subscribed)
+		}
+		if !subscribed {
+			missing = append(missing, channel)
+		}
+	}
+	return missing, nil
+}
+
+// ForgetSubscriptions drops the cached membership of a user, e.g. when they
+// say they've just joined.
+func ForgetSubscriptions(userID int64) {
+	forceSubMutex.Lock()
+	defer forceSubMutex.Unlock()
+	delete(subscriptions, userID)
+}
+
+func cachedSubscription(userID int64, channel ForceSubChannel) (bool, bool) {
+	forceSubMutex.Lock()
+	defer forceSubMutex.Unlock()
+	sub, ok := subscriptions[userID][channel.key()]
+	if !ok || time.Now().After(sub.expires) {
+		return false, false
+	}
+	return sub.subscribed, true
+}
+
+func cacheSubscription(userID int64, channel ForceSubChannel, subscribed bool) {
+	forceSubMutex.Lock()
+	defer forceSubMutex.Unlock()
+	now := time.Now()
+	// drop the users whose results have all expired now and then, so the
+	// map doesn't grow forever
+	if len(subscriptions) > 10000 {
+		for id, subs := range subscriptions {
+			for key, sub := range subs {
+				if now.After(sub.expires) {
+					delete(subs, key)
+				}
+			}
+			if len(subs) == 0 {
+				delete(subscriptions, id)
+			}
+		}
+	}
+	if subscriptions[userID] == nil {
+		subscriptions[userID] = make(map[string]subscription)
+	}
+	subscriptions[userID][channel.key()] = subscription{
+		subscribed: subscribed,
+		expires:    now.Add(time.Duration(config.ValueOf.ForceSubTTL) * time.Second),
+	}
+}
+
+func checkSubscription(ctx context.Context, client *tg.Client, peerStorage *storage.PeerStorage, userID int64, channel ForceSubChannel) (bool, error) {
+	inputChannel, err := resolveForceSubChannel(ctx, client, peerStorage, channel)
+	if err != nil {
+		return false, err
+	}
+	participant, err := client.ChannelsGetParticipant(ctx, &tg.ChannelsGetParticipantRequest{
+		Channel:     inputChannel,
+		Participant: &tg.InputPeerUser{UserID: userID},
+	})
+	if err != nil {
+		if tgerr.Is(err, "USER_NOT_PARTICIPANT", "PARTICIPANT_NOT_EXIST") {
+			return false, nil
+		}
+		return false, err
+	}
+	return isSubscribed(participant.Participant), nil
+}
+
+// isSubscribed reports whether a participant counts as subscribed. Users
+// who left and banned users are not. Restricted members, such as muted
+// ones, are returned as banned too; they count as subscribed while they are
+// still in the channel and can read it.
+func isSubscribed(participant tg.ChannelParticipantClass) bool {
+	switch p := participant.(type) {
+	case *tg.ChannelParticipantLeft:
+		return false
+	case *tg.ChannelParticipantBanned:
+		return !p.Left && !p.BannedRights.ViewMessages
+	}
+	return true
+}
+
+// resolveForceSubChannel resolves a force-sub channel once, instead of on
+// every message.
+func resolveForceSubChannel(ctx context.Context, client *tg.Client, peerStorage *storage.PeerStorage, channel ForceSubChannel) (*tg.InputChannel, error) {
+	forceSubMutex.Lock()
+	inputChannel, ok := forceSubPeers[channel.key()]
+	forceSubMutex.Unlock()
+	if ok {
+		return inputChannel, nil
+	}
+
+	var chats []tg.ChatClass
+	if channel.Username != "" {
+		resolved, err := client.ContactsResolveUsername(ctx, channel.Username)
+		if err != nil {
+			return nil, err
+		}
+		chats = resolved.GetChats()
+	} else {
+		if peer, ok := peerStorage.GetInputPeerById(channel.ID).(*tg.InputPeerChannel); ok {
+			inputChannel = &tg.InputChannel{ChannelID: peer.ChannelID, AccessHash: peer.AccessHash}
+		} else {
+			res, err := client.ChannelsGetChannels(ctx, []tg.InputChannelClass{&tg.InputChannel{ChannelID: channel.ID}})
+			if err != nil {
+				return nil, err
+			}
+			chats = res.GetChats()
+		}
+	}
+	for _, chat := range chats {
+		if c, ok := chat.(*tg.Channel); ok {
+			peerStorage.AddPeer(c.GetID(), c.AccessHash, storage.TypeChannel, c.Username)
+			inputChannel = c.AsInput()
+			break
+		}
+	}
+	if inputChannel == nil {
+		return nil, fmt.Errorf("channel not found")
+	}
+
+	forceSubMutex.Lock()
+	forceSubPeers[channel.key()] = inputChannel
+	forceSubMutex.Unlock()
+	return inputChannel, nil
+}
@@ -0,0 +1,41 @@
+package utils
+
+import "testing"
+
+func TestParseForceSubChannel(t *testing.T) {
+	tests := []struct {
+		entry   string
+		want    ForceSubChannel
+		wantErr bool
+	}{
+		{entry: "mychannel", want: ForceSubChannel{Username: "mychannel", JoinURL: "https://t.me/mychannel"}},
+		{entry: "@mychannel", want: ForceSubChannel{Username: "mychannel", JoinURL: "https://t.me/mychannel"}},
+		{entry: "https://t.me/mychannel", want: ForceSubChannel{Username: "mychannel", JoinURL: "https://t.me/mychannel"}},
+		{entry: "  @mychannel ", want: ForceSubChannel{Username: "mychannel", JoinURL: "https://t.me/mychannel"}},
+		{entry: "-1001234567890=https://t.me/+AbCdEf", want: ForceSubChannel{ID: 1234567890, JoinURL: "https://t.me/+AbCdEf"}},
+		{entry: "1234567890=https://t.me/+AbCdEf", want: ForceSubChannel{ID: 1234567890, JoinURL: "https://t.me/+AbCdEf"}},
+		{entry: "-1001234567890 = https://t.me/+AbCdEf", want: ForceSubChannel{ID: 1234567890, JoinURL: "https://t.me/+AbCdEf"}},
+		{entry: "-42=https://t.me/+AbCdEf", want: ForceSubChannel{ID: 42, JoinURL: "https://t.me/+AbCdEf"}},
+		{entry: "", wantErr: true},
+		{entry: "@", wantErr: true},
+		// private channels can't be joined without an invite link
+		{entry: "-1001234567890", wantErr: true},
+		{entry: "mychannel=https://t.me/+AbCdEf", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseForceSubChannel(tt.entry)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseForceSubChannel(%q) = %+v, want an error", tt.entry, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseForceSubChannel(%q) returned %v", tt.entry, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseForceSubChannel(%q) = %+v, want %+v", tt.entry, got, tt.want)
+		}
+	}
+}
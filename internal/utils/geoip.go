@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"encoding/csv"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ipRange maps the addresses from start to end, both included, to a
+// country.
+type ipRange struct {
+	start   netip.Addr
+	end     netip.Addr
+	country string
+}
+
+var geoIP struct {
+	sync.RWMutex
+	ranges []ipRange
+}
+
+// LoadGeoIP loads a GeoIP database in CSV format, with rows of
+// "first IP,last IP,country code" such as the free country database of
+// DB-IP. Rows that don't match, like a header, are skipped. An empty path
+// does nothing, and CountryOf returns "" until a database is loaded.
+func LoadGeoIP(path string) error {
+	if path == "" {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	var ranges []ipRange
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(record) < 3 {
+			continue
+		}
+		start, err := netip.ParseAddr(strings.TrimSpace(record[0]))
+		if err != nil {
+			continue
+		}
+		end, err := netip.ParseAddr(strings.TrimSpace(record[1]))
+		if err != nil || start.Is4() != end.Is4() {
+			continue
+		}
+		ranges = append(ranges, ipRange{start: start, end: end, country: strings.ToUpper(strings.TrimSpace(record[2]))})
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].start.Less(ranges[j].start)
+	})
+	geoIP.Lock()
+	geoIP.ranges = ranges
+	geoIP.Unlock()
+	return nil
+}
+
+// CountryOf returns the country code of an IP, or "" if it's unknown.
+func CountryOf(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	geoIP.RLock()
+	defer geoIP.RUnlock()
+	ranges := geoIP.ranges
+	// the last range starting at or before addr
+	i := sort.Search(len(ranges), func(i int) bool {
+		return addr.Less(ranges[i].start)
+	}) - 1
+	if i < 0 || ranges[i].start.Is4() != addr.Is4() || ranges[i].end.Less(addr) {
+		return ""
+	}
+	return ranges[i].country
+}
@@ -53,11 +53,27 @@ func FileFromMedia(media tg.MessageMediaClass) (*types.File, error) {
 		if !ok {
 			return nil, fmt.Errorf("unexpected type %T", media)
 		}
-		var fileName string
+		var fileName, title string
+		var duration int
 		for _, attribute := range document.Attributes {
-			if name, ok := attribute.(*tg.DocumentAttributeFilename); ok {
-				fileName = name.FileName
-				break
+			switch attr := attribute.(type) {
+			case *tg.DocumentAttributeFilename:
+				fileName = attr.FileName
+			case *tg.DocumentAttributeVideo:
+				duration = int(attr.Duration)
+			case *tg.DocumentAttributeAudio:
+				duration = attr.Duration
+				title = attr.Title
+				if attr.Performer != "" && title != "" {
+					title = attr.Performer + " - " + title
+				}
+			}
+		}
+		var thumb string
+		for _, size := range document.Thumbs {
+			// stripped thumbnails can't be downloaded, pick the biggest real one
+			if _, ok := size.(*tg.PhotoStrippedSize); !ok {
+				thumb = size.GetType()
 			}
 		}
 		return &types.File{
@@ -66,6 +82,9 @@ func FileFromMedia(media tg.MessageMediaClass) (*types.File, error) {
 			FileName: fileName,
 			MimeType: document.MimeType,
 			ID:       document.ID,
+			Duration: duration,
+			Title:    title,
+			Thumb:    thumb,
 		}, nil
 	case *tg.MessageMediaPhoto:
 		photo, ok := media.Photo.AsNotEmpty()
@@ -97,8 +116,43 @@ func FileFromMedia(media tg.MessageMediaClass) (*types.File, error) {
 	return nil, fmt.Errorf("unexpected type %T", media)
 }
 
+// MediaType returns the kind of media in a message: video, audio, photo or document.
+func MediaType(media tg.MessageMediaClass) string {
+	switch media := media.(type) {
+	case *tg.MessageMediaPhoto:
+		return "photo"
+	case *tg.MessageMediaDocument:
+		document, ok := media.Document.AsNotEmpty()
+		if !ok {
+			return ""
+		}
+		for _, attribute := range document.Attributes {
+			switch attribute.(type) {
+			case *tg.DocumentAttributeVideo:
+				return "video"
+			case *tg.DocumentAttributeAudio:
+				return "audio"
+			}
+		}
+		return "document"
+	}
+	return ""
+}
+
+func fileCacheKey(messageID int, clientID int64) string {
+	return fmt.Sprintf("file:%d:%d", messageID, clientID)
+}
+
+// ForgetFile drops the cached properties of a message for the given clients,
+// so the next request fetches it from Telegram again.
+func ForgetFile(messageID int, clientIDs ...int64) {
+	for _, clientID := range clientIDs {
+		_ = cache.GetCache().Delete(fileCacheKey(messageID, clientID))
+	}
+}
+
 func FileFromMessage(ctx context.Context, client *gotgproto.Client, messageID int) (*types.File, error) {
-	key := fmt.Sprintf("file:%d:%d", messageID, client.Self.ID)
+	key := fileCacheKey(messageID, client.Self.ID)
 	log := Logger.Named("GetMessageMedia")
 	var cachedMedia types.File
 	err := cache.GetCache().Get(key, &cachedMedia)
@@ -179,61 +233,3 @@ func ForwardMessages(ctx *ext.Context, fromChatId, toChatId int64, messageID int
 	}
 	return update.(*tg.Updates), nil
 }
-
-func IsUserSubscribed(ctx context.Context, client *tg.Client, peerStorage *storage.PeerStorage, userID int64) (bool, error) {
-	if config.ValueOf.ForceSubChannel == "" {
-		return true, nil
-	}
-
-	// Get channel by username using ResolveUsername
-	resolved, err := client.ContactsResolveUsername(ctx, config.ValueOf.ForceSubChannel)
-	if err != nil {
-		return false, err
-	}
-
-	// Find the channel in the resolved peers
-	var targetChannel *tg.Channel
-	for _, peer := range resolved.GetChats() {
-		if channel, ok := peer.(*tg.Channel); ok {
-			targetChannel = channel
-			break
-		}
-	}
-
-	if targetChannel == nil {
-		return false, fmt.Errorf("channel %s not found", config.ValueOf.ForceSubChannel)
-	}
-
-	// Check if user is a participant using ChannelsGetParticipant
-	_, err = client.ChannelsGetParticipant(ctx, &tg.ChannelsGetParticipantRequest{
-		Channel: targetChannel.AsInput(),
-		Participant: &tg.InputPeerUser{
-			UserID: userID,
-		},
-	})
-	if err != nil {
-		// Handle specific error cases
-		switch {
-		case err.Error() == "PARTICIPANT_NOT_EXIST":
-			return false, nil
-		case err.Error() == "CHANNEL_PRIVATE":
-			return false, nil
-		case err.Error() == "CHANNEL_INVALID":
-			return false, nil
-		case err.Error() == "USER_NOT_PARTICIPANT":
-			return false, nil
-		case err.Error() == "USER_CHANNEL_INVALID":
-			return false, nil
-		default:
-			// Log the error for debugging
-			Logger.Error("Error checking channel membership",
-				zap.Error(err),
-				zap.Int64("userID", userID),
-				zap.String("channel", config.ValueOf.ForceSubChannel))
-			return false, nil // Return false instead of error to prevent bot from showing error message
-		}
-	}
-
-	// If we get here, user is a participant
-	return true, nil
-}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"EverythingSuckz/fsb/config"
+	"fmt"
+	"strings"
+)
+
+// GetStreamLink returns the public link of a file stored in the log channel.
+func GetStreamLink(messageID int, fullHash string) string {
+	baseUrl := strings.TrimSuffix(config.ValueOf.WorkerURL, "/")
+	return fmt.Sprintf("%s/%d/%s", baseUrl, messageID, GetShortHash(fullHash))
+}
+
+// GetDownloadLink returns the link that forces the browser to download the file.
+func GetDownloadLink(messageID int, fullHash string) string {
+	return GetStreamLink(messageID, fullHash) + "?d=true"
+}
+
+// GetWatchLink returns the link of the watch page served by this server.
+func GetWatchLink(messageID int, fullHash string) string {
+	baseUrl := strings.TrimSuffix(config.ValueOf.Host, "/")
+	return fmt.Sprintf("%s/watch/%d?hash=%s", baseUrl, messageID, GetShortHash(fullHash))
+}
+
+// GetThumbLink returns the link of a file's thumbnail served by this server.
+func GetThumbLink(messageID int, fullHash string) string {
+	baseUrl := strings.TrimSuffix(config.ValueOf.Host, "/")
+	return fmt.Sprintf("%s/thumb/%d?hash=%s", baseUrl, messageID, GetShortHash(fullHash))
+}
+
+// GetPlaylistLink returns the link of a playlist in the given format (m3u8 or xspf).
+func GetPlaylistLink(playlistID string, format string) string {
+	baseUrl := strings.TrimSuffix(config.ValueOf.Host, "/")
+	return fmt.Sprintf("%s/playlist/%s.%s", baseUrl, playlistID, format)
+}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"EverythingSuckz/fsb/config"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrViewerTokenMissing = errors.New("missing viewer token, open the link from the bot")
+	ErrViewerTokenInvalid = errors.New("invalid viewer token")
+	ErrViewerTokenExpired = errors.New("viewer token expired, tap Refresh in the bot to get a new link")
+	ErrViewerTokenScope   = errors.New("viewer token is for another file")
+)
+
+// FileScope is the scope of the viewer tokens that open the links of a
+// file: /stream, /watch and /thumb.
+func FileScope(messageID int) string {
+	return "f" + strconv.Itoa(messageID)
+}
+
+// PlaylistScope is the scope of the viewer tokens that open a playlist.
+func PlaylistScope(playlistID string) string {
+	return "p" + playlistID
+}
+
+// NewViewerToken signs a token that lets the holder open the links of one
+// file or playlist, its scope, for VIEWER_TOKEN_TTL seconds. The token
+// records the user it was issued to, or the chat for channel posts. Returns
+// "" unless VIEWER_TOKENS is set.
+func NewViewerToken(userID int64, scope string) string {
+	if !config.ValueOf.ViewerTokens {
+		return ""
+	}
+	expires := time.Now().Add(time.Duration(config.ValueOf.ViewerTokenTTL) * time.Second).Unix()
+	payload := strconv.FormatInt(userID, 10) + "." + scope + "." + strconv.FormatInt(expires, 10)
+	return payload + "." + viewerTokenSignature(payload)
+}
+
+// CheckViewerToken validates a token made by NewViewerToken for scope and
+// returns the ID it was issued to.
+func CheckViewerToken(token string, scope string) (int64, error) {
+	if token == "" {
+		return 0, ErrViewerTokenMissing
+	}
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return 0, ErrViewerTokenInvalid
+	}
+	payload, signature := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(viewerTokenSignature(payload))) {
+		return 0, ErrViewerTokenInvalid
+	}
+	parts := strings.Split(payload, ".")
+	if len(parts) != 3 {
+		return 0, ErrViewerTokenInvalid
+	}
+	userID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, ErrViewerTokenInvalid
+	}
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return 0, ErrViewerTokenInvalid
+	}
+	if parts[1] != scope {
+		return userID, ErrViewerTokenScope
+	}
+	if time.Now().Unix() > expires {
+		return userID, ErrViewerTokenExpired
+	}
+	return userID, nil
+}
+
+// WithViewerToken adds a viewer token to a link, leaving it as it is if the
+// token is empty.
+func WithViewerToken(link string, token string) string {
+	if token == "" {
+		return link
+	}
+	separator := "?"
+	if strings.Contains(link, "?") {
+		separator = "&"
+	}
+	return link + separator + "token=" + url.QueryEscape(token)
+}
+
+func viewerTokenSignature(payload string) string {
+	mac := hmac.New(sha256.New, viewerTokenSecret())
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// viewerTokenSecret is VIEWER_TOKEN_SECRET, or else derived from the bot
+// token so tokens survive restarts without any setup.
+func viewerTokenSecret() []byte {
+	if config.ValueOf.TokenSecret != "" {
+		return []byte(config.ValueOf.TokenSecret)
+	}
+	sum := sha256.Sum256([]byte("viewer-token:" + config.ValueOf.BotToken))
+	return sum[:]
+}
+
+// HashIP returns a keyed hash of a client IP, so viewers can be counted
+// without storing their address.
+func HashIP(ip string) string {
+	mac := hmac.New(sha256.New, viewerTokenSecret())
+	mac.Write([]byte("ip:" + ip))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:12])
+}
+
+// LinkStatsToken returns the token that lets the owner of a file read its
+// views from /api/links/<message ID>/stats.
+func LinkStatsToken(messageID int, ownerID int64) string {
+	return viewerTokenSignature("linkstats:" + strconv.Itoa(messageID) + ":" + strconv.FormatInt(ownerID, 10))
+}
+
+// CheckLinkStatsToken reports whether token was issued by LinkStatsToken
+// for the file and its owner.
+func CheckLinkStatsToken(token string, messageID int, ownerID int64) bool {
+	return hmac.Equal([]byte(token), []byte(LinkStatsToken(messageID, ownerID)))
+}
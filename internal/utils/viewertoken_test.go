@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"EverythingSuckz/fsb/config"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckViewerToken(t *testing.T) {
+	config.ValueOf.ViewerTokens = true
+	config.ValueOf.ViewerTokenTTL = 3600
+	config.ValueOf.TokenSecret = "test-secret"
+
+	scope := FileScope(42)
+	valid := NewViewerToken(7, scope)
+	expiredPayload := "7." + scope + "." + strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	expired := expiredPayload + "." + viewerTokenSignature(expiredPayload)
+	lastDot := strings.LastIndexByte(valid, '.')
+
+	tests := []struct {
+		name    string
+		token   string
+		scope   string
+		wantID  int64
+		wantErr error
+	}{
+		{name: "valid", token: valid, scope: scope, wantID: 7},
+		{name: "missing", token: "", scope: scope, wantErr: ErrViewerTokenMissing},
+		{name: "no signature", token: "garbage", scope: scope, wantErr: ErrViewerTokenInvalid},
+		{name: "tampered user", token: "8" + valid[1:], scope: scope, wantErr: ErrViewerTokenInvalid},
+		{name: "tampered scope", token: strings.Replace(valid, scope, FileScope(43), 1), scope: FileScope(43), wantErr: ErrViewerTokenInvalid},
+		{name: "tampered signature", token: valid[:lastDot+1] + "AAAAAAAAAAAAAAAAAAAAAA", scope: scope, wantErr: ErrViewerTokenInvalid},
+		{name: "other file", token: valid, scope: FileScope(43), wantID: 7, wantErr: ErrViewerTokenScope},
+		{name: "playlist of same ID", token: valid, scope: PlaylistScope("42"), wantID: 7, wantErr: ErrViewerTokenScope},
+		{name: "expired", token: expired, scope: scope, wantID: 7, wantErr: ErrViewerTokenExpired},
+		{name: "expired other file", token: expired, scope: FileScope(43), wantID: 7, wantErr: ErrViewerTokenScope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := CheckViewerToken(tt.token, tt.scope)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckViewerToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if id != tt.wantID {
+				t.Errorf("CheckViewerToken() = %d, want %d", id, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestCheckViewerTokenOtherSecret(t *testing.T) {
+	config.ValueOf.ViewerTokens = true
+	config.ValueOf.ViewerTokenTTL = 3600
+	config.ValueOf.TokenSecret = "old-secret"
+	token := NewViewerToken(7, FileScope(42))
+	config.ValueOf.TokenSecret = "new-secret"
+	if _, err := CheckViewerToken(token, FileScope(42)); !errors.Is(err, ErrViewerTokenInvalid) {
+		t.Errorf("CheckViewerToken() error = %v, want %v", err, ErrViewerTokenInvalid)
+	}
+}